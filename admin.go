@@ -0,0 +1,225 @@
+package main
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+func (rl *relay) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rl.cfg.AdminToken == "" {
+			writeJSONError(w, http.StatusForbidden, errCodeAdminDisabled, "Admin API is disabled")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(rl.cfg.AdminToken)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+type registerWebhookRequest struct {
+	TeamID      string `json:"team_id"`
+	EventType   string `json:"event_type"`
+	Endpoint    string `json:"endpoint"`
+	Description string `json:"description"`
+}
+
+type registerWebhookResponse struct {
+	ID       string `json:"id"`
+	Passcode string `json:"passcode"`
+	Endpoint string `json:"endpoint"`
+	Status   string `json:"status"`
+}
+
+func (rl *relay) registerWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if rl.cfg.FigmaAPIToken == "" {
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeNotConfigured, "FIGMA_API_TOKEN is not configured")
+		return
+	}
+
+	var req registerWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON")
+		return
+	}
+
+	if req.TeamID == "" || req.EventType == "" {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "team_id and event_type are required")
+		return
+	}
+
+	if req.Endpoint == "" {
+		if rl.cfg.PublicURL == "" {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "endpoint is required when PUBLIC_URL is not configured")
+			return
+		}
+		req.Endpoint = strings.TrimSuffix(rl.cfg.PublicURL, "/") + "/create-issue"
+	}
+
+	// Register with the current passcode this relay verifies against, so
+	// deliveries from the new webhook are accepted without a config change.
+	sub, err := createFigmaWebhook(r.Context(), rl.cfg, figmaWebhookCreate{
+		EventType:   req.EventType,
+		TeamID:      req.TeamID,
+		Endpoint:    req.Endpoint,
+		Passcode:    rl.cfg.webhookPasscodes()[0],
+		Description: req.Description,
+	})
+	if err != nil {
+		loggerFrom(r.Context()).Error("Failed to register Figma webhook", "team_id", req.TeamID, "event_type", req.EventType, "error", err)
+		writeJSONError(w, http.StatusBadGateway, errCodeFigmaFailed, "Failed to register Figma webhook: "+err.Error())
+		return
+	}
+
+	loggerFrom(r.Context()).Info("Registered Figma webhook", "webhook_id", sub.ID, "team_id", req.TeamID, "event_type", req.EventType)
+	writeJSON(w, http.StatusCreated, registerWebhookResponse{
+		ID:       sub.ID,
+		Passcode: sub.Passcode,
+		Endpoint: sub.Endpoint,
+		Status:   sub.Status,
+	})
+}
+
+type testIssueRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	TeamID      string `json:"teamId"`
+	// LegacyTeamID accepts the team_id spelling earlier clients sent.
+	LegacyTeamID string `json:"team_id"`
+}
+
+// testIssueEventType stands in for the Figma event type in the audit log
+// entries of test issues.
+const testIssueEventType = "TEST_ISSUE"
+
+// maxTestIssueTitle is Linear's limit on issue title length.
+const maxTestIssueTitle = 255
+
+// linearIDPattern matches the UUIDs Linear uses for team IDs.
+var linearIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validate checks the request against the bounds Linear would enforce, so
+// a bad request fails here instead of after a round trip. It returns the
+// problem with each invalid field, or nil. An omitted teamId falls back
+// to LINEAR_TEAM_ID and isn't checked. The handler trims Title before
+// calling it.
+func (req testIssueRequest) validate(maxDescription int) map[string]string {
+	fields := make(map[string]string)
+	switch n := utf8.RuneCountInString(req.Title); {
+	case n == 0:
+		fields["title"] = "is required"
+	case n > maxTestIssueTitle:
+		fields["title"] = fmt.Sprintf("must be at most %d characters, got %d", maxTestIssueTitle, n)
+	}
+	if n := utf8.RuneCountInString(req.Description); n > maxDescription {
+		fields["description"] = fmt.Sprintf("must be at most %d characters, got %d", maxDescription, n)
+	}
+	if req.TeamID != "" && req.LegacyTeamID != "" && req.TeamID != req.LegacyTeamID {
+		fields["teamId"] = "conflicts with team_id"
+	} else if teamID := cmp.Or(req.TeamID, req.LegacyTeamID); teamID != "" && !linearIDPattern.MatchString(teamID) {
+		fields["teamId"] = "must be a Linear team ID (UUID)"
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+type testIssueResponse struct {
+	IssueID string `json:"issue_id"`
+	Title   string `json:"title"`
+	TeamID  string `json:"team_id"`
+}
+
+// testIssueHandler creates a Linear issue through the default endpoint's
+// notifier, bypassing the webhook pipeline, so credentials and team
+// routing can be checked end to end.
+func (rl *relay) testIssueHandler(w http.ResponseWriter, r *http.Request) {
+	linear := rl.endpoints[0].linear()
+	if linear == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeNotConfigured, "Linear is not the configured target")
+		return
+	}
+
+	var req testIssueRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		// A misspelt field like teamID would otherwise be dropped silently
+		// and the issue filed in the default team.
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON")
+		return
+	}
+
+	// Trim once so the title that's checked is the one that's sent and
+	// echoed back.
+	req.Title = strings.TrimSpace(req.Title)
+	if fields := req.validate(rl.cfg.MaxDescriptionChars); fields != nil {
+		loggerFrom(r.Context()).Warn("Rejected invalid test issue request", "fields", fields)
+		writeValidationError(w, fields)
+		return
+	}
+	req.TeamID = cmp.Or(req.TeamID, req.LegacyTeamID, rl.cfg.LinearTeamID)
+	if req.TeamID == "" {
+		writeValidationError(w, map[string]string{"teamId": "is required when LINEAR_TEAM_ID is not configured"})
+		return
+	}
+
+	logger := loggerFrom(r.Context())
+	idemKey, err := idempotencyKey(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	if idemKey != "" {
+		// Keep test issue keys apart from webhook keys on the default endpoint.
+		idemKey = "test-issue|" + idemKey
+		// Reserve the key before checking the store, as the webhook handler
+		// does, so two concurrent requests can't both create an issue.
+		if !rl.pending.reserve(idemKey) {
+			logger.Info("Repeated test issue request while the first is still in progress")
+			writeJSONError(w, http.StatusConflict, errCodeInProgress, "A request with this Idempotency-Key is still in progress")
+			return
+		}
+		defer rl.pending.release(idemKey)
+		if issueID, ok := rl.dedup.Seen(idemKey); ok {
+			logger.Info("Repeated test issue request, returning original issue", "issue_id", issueID)
+			writeJSON(w, http.StatusOK, testIssueResponse{IssueID: issueID, Title: req.Title, TeamID: req.TeamID})
+			return
+		}
+	}
+
+	issue, err := linear.createTestIssue(r.Context(), cmp.Or(idemKey, "test-issue"), LinearIssueInput{
+		Title:       req.Title,
+		Description: req.Description,
+		TeamID:      req.TeamID,
+	})
+	if err != nil {
+		logger.Error("Failed to create test issue", "team_id", req.TeamID, "error", err)
+		writeJSONError(w, http.StatusBadGateway, errCodeLinearFailed, "Failed to create Linear issue: "+err.Error())
+		return
+	}
+
+	logger.Info("Created test issue", "issue_id", issue.ID, "team_id", req.TeamID)
+	if idemKey != "" {
+		if err := rl.dedup.Record(idemKey, issue.ID); err != nil {
+			logger.Error("Failed to record idempotency key", "error", err)
+		}
+	}
+	writeJSON(w, http.StatusCreated, testIssueResponse{IssueID: issue.ID, Title: issue.Title, TeamID: req.TeamID})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestIssueRelay builds a dry-run relay whose default endpoint audits
+// created issues to auditPath.
+func newTestIssueRelay(t *testing.T) (rl *relay, auditPath string) {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.DryRun = true
+	return newTestIssueRelayFor(t, cfg, http.DefaultClient)
+}
+
+func newTestIssueRelayFor(t *testing.T, cfg *Config, client *http.Client) (rl *relay, auditPath string) {
+	t.Helper()
+	cfg.LinearTeamID = "default-team"
+	auditPath = filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := newAuditLog(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { audit.Close() })
+	linear, err := newLinearNotifier(cfg, client, linearShared{audit: audit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &relay{
+		cfg:       cfg,
+		dedup:     newMemoryDedupStore(time.Minute),
+		pending:   newPendingKeys(),
+		endpoints: []*endpoint{{path: defaultEndpointPath, cfg: cfg, notifiers: []Notifier{linear}}},
+	}, auditPath
+}
+
+// postTestIssue sends body to the test-issue handler and decodes whichever
+// response shape came back.
+func postTestIssue(t *testing.T, rl *relay, body string, headers ...string) (int, testIssueResponse, errorBody) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/test-issue", strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	rl.testIssueHandler(rec, req)
+
+	var ok testIssueResponse
+	var failed errorBody
+	if rec.Code < 300 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &ok); err != nil {
+			t.Fatalf("decoding response %q: %v", rec.Body, err)
+		}
+	} else if err := json.Unmarshal(rec.Body.Bytes(), &failed); err != nil {
+		t.Fatalf("decoding error response %q: %v", rec.Body, err)
+	}
+	return rec.Code, ok, failed
+}
+
+func TestTestIssueHandlerTeamID(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantTeam   string
+		wantCode   string
+		wantField  string
+	}{
+		{"teamId", `{"title":"t","teamId":"` + testTeamID + `"}`, http.StatusCreated, testTeamID, "", ""},
+		{"legacy team_id", `{"title":"t","team_id":"` + testTeamID + `"}`, http.StatusCreated, testTeamID, "", ""},
+		{"both agree", `{"title":"t","teamId":"` + testTeamID + `","team_id":"` + testTeamID + `"}`, http.StatusCreated, testTeamID, "", ""},
+		{"omitted falls back to LINEAR_TEAM_ID", `{"title":"t"}`, http.StatusCreated, "default-team", "", ""},
+		{"conflicting spellings", `{"title":"t","teamId":"` + testTeamID + `","team_id":"4f2b1c4d-0000-4000-8000-123456789abc"}`, http.StatusBadRequest, "", errCodeInvalidRequest, "teamId"},
+		{"misspelt field", `{"tilte":"t","teamId":"` + testTeamID + `"}`, http.StatusBadRequest, "", errCodeInvalidRequest, ""},
+		{"malformed JSON", `{"title":`, http.StatusBadRequest, "", errCodeInvalidJSON, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, _ := newTestIssueRelay(t)
+			status, ok, failed := postTestIssue(t, rl, tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (error %+v)", status, tt.wantStatus, failed.Error)
+			}
+			if ok.TeamID != tt.wantTeam {
+				t.Errorf("team_id = %q, want %q", ok.TeamID, tt.wantTeam)
+			}
+			if failed.Error.Code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", failed.Error.Code, tt.wantCode)
+			}
+			if tt.wantField != "" && failed.Error.Fields[tt.wantField] == "" {
+				t.Errorf("fields = %v, want an entry for %s", failed.Error.Fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestTestIssueRequestValidate(t *testing.T) {
+	const maxDescription = 10
+	tests := []struct {
+		name       string
+		req        testIssueRequest
+		wantFields []string
+	}{
+		{"valid", testIssueRequest{Title: "Check routing", TeamID: testTeamID}, nil},
+		{"empty title", testIssueRequest{}, []string{"title"}},
+		{"255 runes", testIssueRequest{Title: strings.Repeat("a", 255)}, nil},
+		{"256 runes", testIssueRequest{Title: strings.Repeat("a", 256)}, []string{"title"}},
+		// 255 runes but 765 bytes: the limit is on characters, not bytes.
+		{"multibyte title", testIssueRequest{Title: strings.Repeat("界", 255)}, nil},
+		{"multibyte title too long", testIssueRequest{Title: strings.Repeat("界", 256)}, []string{"title"}},
+		{"non-UUID team ID", testIssueRequest{Title: "t", TeamID: "ENG"}, []string{"teamId"}},
+		{"non-UUID legacy team ID", testIssueRequest{Title: "t", LegacyTeamID: "ENG"}, []string{"teamId"}},
+		{"description at limit", testIssueRequest{Title: "t", Description: strings.Repeat("d", maxDescription)}, nil},
+		{"oversized description", testIssueRequest{Title: "t", Description: strings.Repeat("d", maxDescription+1)}, []string{"description"}},
+		{"several problems", testIssueRequest{TeamID: "ENG", Description: strings.Repeat("d", maxDescription+1)}, []string{"title", "teamId", "description"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := tt.req.validate(maxDescription)
+			if len(fields) != len(tt.wantFields) {
+				t.Fatalf("validate() = %v, want problems with %v", fields, tt.wantFields)
+			}
+			for _, f := range tt.wantFields {
+				if fields[f] == "" {
+					t.Errorf("validate() = %v, want a problem with %s", fields, f)
+				}
+			}
+		})
+	}
+}
+
+func TestTestIssueHandlerTrimsTitle(t *testing.T) {
+	tests := []struct {
+		name       string
+		title      string
+		wantStatus int
+		wantTitle  string
+	}{
+		{"whitespace only", " \t\n ", http.StatusBadRequest, ""},
+		{"padded", "  Check routing \n", http.StatusCreated, "Check routing"},
+		// Padding doesn't count towards the limit once it's trimmed.
+		{"padded to 257 runes", " " + strings.Repeat("a", 255) + " ", http.StatusCreated, strings.Repeat("a", 255)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(testIssueRequest{Title: tt.title})
+			rl, _ := newTestIssueRelay(t)
+			status, ok, failed := postTestIssue(t, rl, string(body))
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (error %+v)", status, tt.wantStatus, failed.Error)
+			}
+			if ok.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", ok.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestTestIssueHandlerWritesAuditLog(t *testing.T) {
+	rl, auditPath := newTestIssueRelay(t)
+	status, ok, failed := postTestIssue(t, rl, `{"title":"Check routing","teamId":"`+testTeamID+`"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d, want %d (error %+v)", status, http.StatusCreated, failed.Error)
+	}
+
+	entries, err := readAuditEntries(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("audit log has %d entries, want 1", len(entries))
+	}
+	got := entries[0]
+	if got.Status != auditCreated || got.EventType != testIssueEventType || got.TeamID != testTeamID || got.IssueID != ok.IssueID {
+		t.Errorf("audit entry = %+v, want a created %s entry for issue %s in team %s", got, testIssueEventType, ok.IssueID, testTeamID)
+	}
+}
+
+func TestTestIssueHandlerConcurrentIdempotencyKey(t *testing.T) {
+	var (
+		requests atomic.Int32
+		arrived  = make(chan struct{})
+		proceed  = make(chan struct{})
+	)
+	linear := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the first request is held, so one that slips past the
+		// reservation shows up as a second issue rather than a hang.
+		if requests.Add(1) == 1 {
+			close(arrived)
+			<-proceed
+		}
+		io.WriteString(w, `{"data":{"issueCreate":{"issue":{"id":"issue-1","title":"Check routing","url":"https://linear.app/test/issue/ENG-1"}}}}`)
+	}))
+	defer linear.Close()
+
+	cfg := defaultConfig()
+	cfg.LinearAPIURL = linear.URL
+	cfg.LinearAPIKey = "lin_api_test"
+	rl, _ := newTestIssueRelayFor(t, cfg, linear.Client())
+	const body = `{"title":"Check routing"}`
+
+	first := make(chan int)
+	go func() {
+		status, _, _ := postTestIssue(t, rl, body, idempotencyKeyHeader, "check-1")
+		first <- status
+	}()
+	<-arrived
+
+	// The first request is still waiting on Linear.
+	status, _, failed := postTestIssue(t, rl, body, idempotencyKeyHeader, "check-1")
+	if status != http.StatusConflict || failed.Error.Code != errCodeInProgress {
+		t.Errorf("concurrent repeat: status = %d, code %q, want %d %s", status, failed.Error.Code, http.StatusConflict, errCodeInProgress)
+	}
+
+	close(proceed)
+	if status := <-first; status != http.StatusCreated {
+		t.Fatalf("first request: status = %d, want %d", status, http.StatusCreated)
+	}
+	status, ok, _ := postTestIssue(t, rl, body, idempotencyKeyHeader, "check-1")
+	if status != http.StatusOK || ok.IssueID != "issue-1" {
+		t.Errorf("repeat after creation: status = %d, issue %q, want %d issue-1", status, ok.IssueID, http.StatusOK)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Linear received %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audit entry statuses. Failed entries carry the full event so relay
+// replay can re-drive them.
+const (
+	auditCreated = "created"
+	auditFailed  = "failed"
+)
+
+// auditEntry is one line of the audit log, written for every Linear issue
+// the relay creates and every event it failed to deliver.
+type auditEntry struct {
+	Time        time.Time     `json:"time"`
+	Status      string        `json:"status"`
+	Fingerprint string        `json:"fingerprint"`
+	Endpoint    string        `json:"endpoint,omitempty"`
+	EventType   string        `json:"event_type"`
+	FileKey     string        `json:"file_key"`
+	TeamID      string        `json:"team_id,omitempty"`
+	IssueID     string        `json:"issue_id,omitempty"`
+	IssueURL    string        `json:"issue_url,omitempty"`
+	ParentID    string        `json:"parent_id,omitempty"`
+	Attempts    int           `json:"attempts,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Event       *FigmaWebhook `json:"event,omitempty"`
+}
+
+// auditLog appends JSON lines to a file; it backs both AUDIT_LOG_PATH and
+// DEAD_LETTER_PATH. Writes are serialized so concurrent workers never
+// interleave lines. A nil *auditLog discards everything, which is how
+// either file is disabled.
+type auditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &auditLog{f: f}, nil
+}
+
+func (a *auditLog) write(entry auditEntry) error {
+	if a == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.f.Write(line)
+	return err
+}
+
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.f.Close()
+}
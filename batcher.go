@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// publishBatcher coalesces LIBRARY_PUBLISH events for the same file that
+// arrive within a window into a single job, so a large publish that Figma
+// splits across several deliveries still produces one issue.
+type publishBatcher struct {
+	mu      sync.Mutex
+	window  time.Duration
+	pending map[string]*pendingBatch
+	closed  bool
+	flush   func(issueJob)
+}
+
+type pendingBatch struct {
+	job   issueJob
+	timer *time.Timer
+}
+
+func newPublishBatcher(window time.Duration, flush func(issueJob)) *publishBatcher {
+	return &publishBatcher{
+		window:  window,
+		pending: make(map[string]*pendingBatch),
+		flush:   flush,
+	}
+}
+
+// Add reports whether the job was taken into a batch. It returns false once
+// the batcher has been closed, leaving the caller to handle the job directly.
+func (b *publishBatcher) Add(job issueJob) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+
+	key := job.endpoint.path + "|" + job.event.FileKey
+	batch, ok := b.pending[key]
+	if !ok {
+		batch = &pendingBatch{job: job}
+		batch.timer = time.AfterFunc(b.window, func() { b.expire(key, batch) })
+		b.pending[key] = batch
+		job.logger.Info("Started publish batch", "window", b.window.String())
+		return true
+	}
+
+	batch.job = mergePublishJobs(batch.job, job)
+	job.logger.Info("Added event to publish batch", "batched_events", len(batch.job.dedupKeys))
+	return true
+}
+
+func (b *publishBatcher) expire(key string, batch *pendingBatch) {
+	b.mu.Lock()
+	// A flush during shutdown may already have taken this batch.
+	if b.pending[key] != batch {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	b.mu.Unlock()
+
+	b.flush(batch.job)
+}
+
+// Close stops accepting events and flushes every pending batch immediately
+// rather than waiting for their windows to expire.
+func (b *publishBatcher) Close() {
+	b.mu.Lock()
+	b.closed = true
+	batches := b.pending
+	b.pending = make(map[string]*pendingBatch)
+	b.mu.Unlock()
+
+	if len(batches) > 0 {
+		slog.Info("Flushing pending publish batches", "batches", len(batches))
+	}
+	for _, batch := range batches {
+		batch.timer.Stop()
+		b.flush(batch.job)
+	}
+}
+
+func mergePublishJobs(into, from issueJob) issueJob {
+	merged := from
+	merged.logger = into.logger
+	merged.dedupKeys = append(slices.Clone(into.dedupKeys), from.dedupKeys...)
+
+	merged.event.CreatedComponents = append(slices.Clone(into.event.CreatedComponents), from.event.CreatedComponents...)
+	merged.event.ModifiedComponents = append(slices.Clone(into.event.ModifiedComponents), from.event.ModifiedComponents...)
+	merged.event.DeletedComponents = append(slices.Clone(into.event.DeletedComponents), from.event.DeletedComponents...)
+	if merged.event.Description == "" {
+		merged.event.Description = into.event.Description
+	}
+
+	return merged
+}
+
+func (rl *relay) flushBatch(job issueJob) {
+	job.logger.Info("Flushing publish batch", "batched_events", len(job.dedupKeys))
+	if !rl.queue.Enqueue(context.Background(), job) {
+		job.logger.Error("Work queue is full or closed, dropping publish batch")
+		rl.dropJob(job)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("linear circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops calls to Linear after threshold consecutive
+// failures. Once cooldown has passed it lets a single trial call through;
+// success closes the circuit again and failure re-opens it.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports errCircuitOpen when the call should be skipped. A nil
+// breaker allows everything.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return errCircuitOpen
+		}
+		b.transition(breakerHalfOpen)
+		b.trial = true
+		return nil
+	case breakerHalfOpen:
+		if b.trial {
+			return errCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// record feeds the outcome of an allowed call back into the breaker.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if !isOutage(err) {
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.transition(breakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		if b.state != breakerOpen {
+			b.transition(breakerOpen)
+		}
+	}
+}
+
+func (b *circuitBreaker) transition(to breakerState) {
+	slog.Warn("Linear circuit breaker changed state", "from", b.state.String(), "to", to.String(), "consecutive_failures", b.failures)
+	b.state = to
+	linearBreakerState.Set(float64(to))
+}
+
+// isOutage reports whether err suggests Linear itself is unavailable, as
+// opposed to rejecting this particular request.
+func isOutage(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var gqlErr *linearGraphQLError
+	if errors.As(err, &gqlErr) {
+		return false
+	}
+	var statusErr *linearStatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.StatusCode)
+	}
+	return true
+}
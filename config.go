@@ -0,0 +1,782 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigPath = "relay.yaml"
+
+// EventSettings holds per-event-type options. In the config file they live
+// under events.<EVENT_TYPE>; env vars prefixed with the event type (e.g.
+// LIBRARY_PUBLISH_PRIORITY=2) override them.
+type EventSettings struct {
+	Priority   int      `yaml:"priority"`
+	LabelIDs   []string `yaml:"label_ids"`
+	LabelNames []string `yaml:"label_names"`
+	ProjectID  string   `yaml:"project_id"`
+	StateID    string   `yaml:"state_id"`
+	// TemplateID bases new issues on a saved Linear template, which fills
+	// in its checklist, labels and other fields. Values the relay sends
+	// explicitly, including the generated description, take precedence
+	// over the template's.
+	TemplateID string `yaml:"template_id"`
+	// Enabled defaults to true when unset, except for opt-in events such
+	// as DEV_MODE_STATUS_UPDATE.
+	Enabled *bool `yaml:"enabled"`
+}
+
+// EndpointConfig adds an extra webhook path, /create-issue/<name>, that
+// files issues with its own Linear credentials and default team. An
+// endpoint with its own Figma passcode(s) accepts only those, so one org's
+// passcode can't sign deliveries to another's endpoint; without one it
+// falls back to FIGMA_WEBHOOK_PASSCODE.
+type EndpointConfig struct {
+	Name                 string `yaml:"name"`
+	LinearAPIKey         string `yaml:"linear_api_key"`
+	LinearTeamID         string `yaml:"linear_team_id"`
+	FigmaWebhookPasscode string `yaml:"figma_webhook_passcode"`
+}
+
+// Config is assembled in four layers, each overriding the one before it:
+//
+//  1. built-in defaults
+//  2. the YAML file named by RELAY_CONFIG (default relay.yaml, optional),
+//     or with CONFIG_BACKEND=consul the document at CONSUL_KEY, with the
+//     profile named by RELAY_ENV applied over its base section
+//  3. environment variables
+//  4. command-line flags, for the handful of settings that have one
+//
+// YAML keys are the lowercased names of the matching env vars, so
+// LINEAR_TEAM_ID in the environment wins over linear_team_id in the file.
+type Config struct {
+	Port   string `yaml:"port"`
+	Target string `yaml:"target"`
+	// LogLevel is debug, info, warn or error. Request payloads are only
+	// logged at debug.
+	LogLevel     string `yaml:"log_level"`
+	MaxBodyBytes int64  `yaml:"max_body_bytes"`
+	// FormPayloadField names the form field that holds the JSON payload
+	// when a sender posts application/x-www-form-urlencoded.
+	FormPayloadField string `yaml:"form_payload_field"`
+
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// MaxEventAge rejects deliveries whose timestamp is older than this;
+	// zero disables the check.
+	MaxEventAge time.Duration `yaml:"max_event_age"`
+
+	// LinearAuthScheme is raw (personal API key), bearer (OAuth token) or
+	// auto (chosen by token prefix). LinearTeamName is resolved to an ID
+	// through the API when LinearTeamID is unset.
+	LinearAPIURL      string        `yaml:"linear_api_url"`
+	LinearAPIKey      string        `yaml:"linear_api_key"`
+	LinearAuthScheme  string        `yaml:"linear_auth_scheme"`
+	LinearTeamID      string        `yaml:"linear_team_id"`
+	LinearTeamName    string        `yaml:"linear_team_name"`
+	LinearNameRefresh time.Duration `yaml:"linear_name_refresh_interval"`
+	LinearProjectID   string        `yaml:"linear_project_id"`
+	LinearStateID     string        `yaml:"linear_state_id"`
+	DryRun            bool          `yaml:"dry_run"`
+
+	// LinearMode is create (new issue per event) or comment (comment on
+	// LinearCommentIssueID).
+	LinearMode           string `yaml:"linear_mode"`
+	LinearCommentIssueID string `yaml:"linear_comment_issue_id"`
+
+	// LinearMaxConcurrency caps simultaneous Linear calls; calls that can't
+	// get a slot within LinearAcquireTimeout fail. LinearBreakerThreshold
+	// consecutive outage-like failures open the circuit for
+	// LinearBreakerCooldown; zero disables the breaker. LinearMaxRetries
+	// is the total number of attempts per request, including the first.
+	LinearMaxRetries       int           `yaml:"linear_max_retries"`
+	LinearHTTPTimeout      time.Duration `yaml:"linear_http_timeout"`
+	LinearMaxConcurrency   int           `yaml:"linear_max_concurrency"`
+	LinearAcquireTimeout   time.Duration `yaml:"linear_acquire_timeout"`
+	LinearBreakerThreshold int           `yaml:"linear_breaker_threshold"`
+	LinearBreakerCooldown  time.Duration `yaml:"linear_breaker_cooldown"`
+	// LinearTeamRatePerMinute paces outbound Linear calls per team; calls
+	// over the limit wait rather than fail. Zero disables it.
+	LinearTeamRatePerMinute int `yaml:"linear_team_rate_limit_per_minute"`
+	LinearTeamRateBurst     int `yaml:"linear_team_rate_limit_burst"`
+	// The outbound connection pool. LinearHTTP2 negotiates HTTP/2 over TLS
+	// so concurrent calls share one connection per host. A zero
+	// LinearMaxConnsPerHost leaves connections per host unlimited.
+	LinearHTTP2               bool          `yaml:"linear_http2"`
+	LinearMaxConnsPerHost     int           `yaml:"linear_max_conns_per_host"`
+	LinearMaxIdleConnsPerHost int           `yaml:"linear_max_idle_conns_per_host"`
+	LinearIdleConnTimeout     time.Duration `yaml:"linear_idle_conn_timeout"`
+
+	// EgressHeaders are added to every outbound request (Linear, Figma,
+	// Slack, GitHub), e.g. for an authenticating egress proxy.
+	EgressHeaders map[string]string `yaml:"egress_headers"`
+	// LinearProxyURL sends all outbound requests through this proxy. When
+	// unset, HTTPS_PROXY, HTTP_PROXY and NO_PROXY apply as usual.
+	LinearProxyURL string `yaml:"linear_proxy_url"`
+
+	FigmaWebhookPasscode string        `yaml:"figma_webhook_passcode"`
+	FigmaAPIToken        string        `yaml:"figma_api_token"`
+	FigmaFileCacheTTL    time.Duration `yaml:"figma_file_cache_ttl"`
+	// FigmaEnrichTimeout bounds the file metadata lookup made for each
+	// event. Enrichment is best-effort: on timeout the issue is created
+	// from the webhook payload alone.
+	FigmaEnrichTimeout time.Duration `yaml:"figma_enrich_timeout"`
+	// AttachThumbnail embeds the file thumbnail in the issue description.
+	// It needs FIGMA_API_TOKEN.
+	AttachThumbnail bool `yaml:"attach_thumbnail"`
+
+	AdminToken string `yaml:"admin_token"`
+	// AdminCORSOrigins lists the browser origins, such as
+	// https://ops.example.com, allowed to call the admin API. "*" allows
+	// any origin. Empty disables CORS.
+	AdminCORSOrigins []string `yaml:"admin_cors_origins"`
+	PublicURL        string   `yaml:"public_url"`
+
+	SlackWebhookURL string `yaml:"slack_webhook_url"`
+
+	GitHubRepo   string   `yaml:"github_repo"`
+	GitHubToken  string   `yaml:"github_token"`
+	GitHubLabels []string `yaml:"github_labels"`
+
+	AllowedFileKeys []string          `yaml:"allowed_file_keys"`
+	FileTeamMap     map[string]string `yaml:"figma_file_team_map"`
+	// RoutingMode picks which map chooses the Linear team: "file" keys
+	// FileTeamMap by file key, "team" keys TeamTeamMap by Figma team ID.
+	RoutingMode string            `yaml:"routing_mode"`
+	TeamTeamMap map[string]string `yaml:"figma_team_team_map"`
+	// UserAssigneeMap maps Figma user IDs or handles to Linear user IDs.
+	// Comments go to the first mapped @-mention, everything else to the
+	// user who triggered the event.
+	UserAssigneeMap map[string]string `yaml:"figma_user_map"`
+
+	EventSettings map[string]EventSettings `yaml:"events"`
+
+	// CommentTriggerRegex limits FILE_COMMENT issues to comments whose text
+	// matches. Empty means every comment creates an issue.
+	CommentTriggerRegex string `yaml:"comment_trigger_regex"`
+
+	TitleTemplate       string `yaml:"issue_title_template"`
+	DescriptionTemplate string `yaml:"issue_description_template"`
+	MaxDescriptionChars int    `yaml:"max_description_chars"`
+	// TitlePrefix and TitleSuffix wrap every issue title, after templates
+	// are applied.
+	TitlePrefix string `yaml:"issue_title_prefix"`
+	TitleSuffix string `yaml:"issue_title_suffix"`
+	// SourceFooter appends a searchable "Source: relay" footer naming the
+	// file and event to every description.
+	SourceFooter bool `yaml:"issue_source_footer"`
+
+	RateLimitPerMinute int `yaml:"rate_limit_per_minute"`
+	RateLimitBurst     int `yaml:"rate_limit_burst"`
+
+	BatchWindow time.Duration `yaml:"batch_window"`
+	// DigestMode collects events instead of filing an issue per event, and
+	// files one summary issue per endpoint every DigestInterval.
+	DigestMode     bool          `yaml:"digest_mode"`
+	DigestInterval time.Duration `yaml:"digest_interval"`
+	// PerFileCooldown allows at most one issue per file and event type in
+	// each period. Later events in the period are added as comments on
+	// that issue. Zero disables the cooldown.
+	PerFileCooldown time.Duration `yaml:"per_file_cooldown"`
+	// DebounceInterval holds each file's latest FILE_UPDATE and only files
+	// an issue once no newer update has arrived for that long. Zero
+	// disables debouncing.
+	DebounceInterval time.Duration `yaml:"debounce_interval"`
+	// PublishSubIssues files a Linear sub-issue per changed component under
+	// each LIBRARY_PUBLISH issue.
+	PublishSubIssues bool `yaml:"publish_sub_issues"`
+
+	// More than EscalationThreshold publishes of one file within
+	// EscalationWindow raise the issue to EscalationPriority. A zero
+	// window disables escalation.
+	EscalationWindow    time.Duration `yaml:"escalation_window"`
+	EscalationThreshold int           `yaml:"escalation_threshold"`
+	EscalationPriority  int           `yaml:"escalation_priority"`
+
+	EventHistorySize int `yaml:"event_history_size"`
+
+	// AuditLogPath, when set, gets one JSON line per created Linear issue.
+	AuditLogPath string `yaml:"audit_log_path"`
+	// DeadLetterPath, when set, gets one JSON line per event no target
+	// could deliver, in a format relay replay accepts.
+	DeadLetterPath string `yaml:"dead_letter_path"`
+
+	DedupTTL        time.Duration `yaml:"dedup_ttl"`
+	DedupBackend    string        `yaml:"dedup_backend"`
+	DedupPath       string        `yaml:"dedup_path"`
+	WorkerCount     int           `yaml:"worker_count"`
+	QueueSize       int           `yaml:"queue_size"`
+	QueueFullPolicy string        `yaml:"queue_full_policy"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// Endpoints can only be set in the config file.
+	Endpoints []EndpointConfig `yaml:"endpoints"`
+
+	titleTemplate       *template.Template
+	descriptionTemplate *template.Template
+	commentTrigger      *regexp.Regexp
+	validateOnly        bool
+	logLevel            slog.Level
+	// endpointName is set on the copies forEndpoint makes, and is empty
+	// for the default endpoint.
+	endpointName string
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Port:                      "80",
+		Target:                    "linear",
+		LogLevel:                  "info",
+		MaxBodyBytes:              1 << 20,
+		FormPayloadField:          "payload",
+		ReadHeaderTimeout:         5 * time.Second,
+		ReadTimeout:               15 * time.Second,
+		WriteTimeout:              30 * time.Second,
+		IdleTimeout:               2 * time.Minute,
+		MaxEventAge:               5 * time.Minute,
+		MaxDescriptionChars:       60000,
+		RoutingMode:               "file",
+		EscalationThreshold:       3,
+		EscalationPriority:        2,
+		LinearAPIURL:              defaultLinearAPIURL,
+		LinearAuthScheme:          "raw",
+		LinearMode:                "create",
+		LinearMaxRetries:          3,
+		LinearHTTPTimeout:         10 * time.Second,
+		LinearMaxConcurrency:      5,
+		LinearHTTP2:               true,
+		LinearMaxIdleConnsPerHost: 20,
+		LinearIdleConnTimeout:     90 * time.Second,
+		LinearNameRefresh:         time.Hour,
+		LinearBreakerThreshold:    5,
+		LinearBreakerCooldown:     30 * time.Second,
+		EventHistorySize:          100,
+		LinearAcquireTimeout:      30 * time.Second,
+		FigmaFileCacheTTL:         5 * time.Minute,
+		FigmaEnrichTimeout:        3 * time.Second,
+		DedupTTL:                  10 * time.Minute,
+		DedupBackend:              "memory",
+		DedupPath:                 "relay-dedup.db",
+		WorkerCount:               4,
+		QueueSize:                 100,
+		QueueFullPolicy:           "drop",
+		ShutdownTimeout:           15 * time.Second,
+		DebounceInterval:          time.Minute,
+		PerFileCooldown:           time.Hour,
+		DigestInterval:            24 * time.Hour,
+	}
+}
+
+// loadConfigFile decodes the config document from the configured provider
+// over cfg.
+func loadConfigFile(cfg *Config) error {
+	provider, err := configProvider()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), consulFetchTimeout)
+	defer cancel()
+	doc, source, err := provider.Load(ctx)
+	if err != nil || doc == nil {
+		return err
+	}
+
+	file := configFile{Config: cfg}
+	dec := yaml.NewDecoder(bytes.NewReader(doc))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid %s: %w", source, err)
+	}
+
+	env := os.Getenv("RELAY_ENV")
+	if env == "" {
+		return nil
+	}
+	profile, ok := file.Profiles[env]
+	if !ok {
+		return fmt.Errorf("%s has no profile %q for RELAY_ENV", source, env)
+	}
+	// Re-decoding the profile onto the base config overrides only the keys
+	// it sets. Maps are merged key by key; lists are replaced.
+	b, err := yaml.Marshal(&profile)
+	if err != nil {
+		return fmt.Errorf("invalid profile %q in %s: %w", env, source, err)
+	}
+	dec = yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid profile %q in %s: %w", env, source, err)
+	}
+	return nil
+}
+
+// configFile is the layout of the YAML config file: the base settings at
+// the top level, plus named profiles under profiles.<name> that RELAY_ENV
+// selects and layers over the base.
+type configFile struct {
+	*Config  `yaml:",inline"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+func loadConfig(args []string) (*Config, error) {
+	cfg := defaultConfig()
+	if err := loadConfigFile(cfg); err != nil {
+		return nil, err
+	}
+
+	e := &envReader{}
+
+	cfg.Port = e.str("PORT", cfg.Port)
+	cfg.Target = e.str("TARGET", cfg.Target)
+	cfg.LogLevel = e.str("LOG_LEVEL", cfg.LogLevel)
+	cfg.MaxBodyBytes = int64(e.integer("MAX_BODY_BYTES", int(cfg.MaxBodyBytes)))
+	cfg.FormPayloadField = e.str("FORM_PAYLOAD_FIELD", cfg.FormPayloadField)
+	cfg.MaxEventAge = e.duration("MAX_EVENT_AGE", cfg.MaxEventAge)
+	cfg.TLSCertFile = e.str("TLS_CERT_FILE", cfg.TLSCertFile)
+	cfg.TLSKeyFile = e.str("TLS_KEY_FILE", cfg.TLSKeyFile)
+	cfg.ReadHeaderTimeout = e.duration("READ_HEADER_TIMEOUT", cfg.ReadHeaderTimeout)
+	cfg.ReadTimeout = e.duration("READ_TIMEOUT", cfg.ReadTimeout)
+	cfg.WriteTimeout = e.duration("WRITE_TIMEOUT", cfg.WriteTimeout)
+	cfg.IdleTimeout = e.duration("IDLE_TIMEOUT", cfg.IdleTimeout)
+
+	cfg.LinearAPIURL = e.str("LINEAR_API_URL", cfg.LinearAPIURL)
+	cfg.LinearAPIKey = e.str("LINEAR_API_KEY", cfg.LinearAPIKey)
+	cfg.LinearAuthScheme = e.str("LINEAR_AUTH_SCHEME", cfg.LinearAuthScheme)
+	cfg.LinearTeamID = e.str("LINEAR_TEAM_ID", cfg.LinearTeamID)
+	cfg.LinearTeamName = e.str("LINEAR_TEAM_NAME", cfg.LinearTeamName)
+	cfg.LinearProjectID = e.str("LINEAR_PROJECT_ID", cfg.LinearProjectID)
+	cfg.LinearMode = e.str("LINEAR_MODE", cfg.LinearMode)
+	cfg.LinearCommentIssueID = e.str("LINEAR_COMMENT_ISSUE_ID", cfg.LinearCommentIssueID)
+	cfg.LinearStateID = e.str("LINEAR_STATE_ID", cfg.LinearStateID)
+	cfg.LinearNameRefresh = e.duration("LINEAR_NAME_REFRESH_INTERVAL", cfg.LinearNameRefresh)
+	cfg.LinearMaxRetries = e.integer("LINEAR_MAX_RETRIES", cfg.LinearMaxRetries)
+	cfg.LinearHTTPTimeout = e.duration("LINEAR_HTTP_TIMEOUT", cfg.LinearHTTPTimeout)
+	cfg.LinearMaxConcurrency = e.integer("LINEAR_MAX_CONCURRENCY", cfg.LinearMaxConcurrency)
+	cfg.LinearHTTP2 = e.boolean("LINEAR_HTTP2", cfg.LinearHTTP2)
+	cfg.LinearMaxConnsPerHost = e.integer("LINEAR_MAX_CONNS_PER_HOST", cfg.LinearMaxConnsPerHost)
+	cfg.LinearMaxIdleConnsPerHost = e.integer("LINEAR_MAX_IDLE_CONNS_PER_HOST", cfg.LinearMaxIdleConnsPerHost)
+	cfg.LinearIdleConnTimeout = e.duration("LINEAR_IDLE_CONN_TIMEOUT", cfg.LinearIdleConnTimeout)
+	cfg.LinearAcquireTimeout = e.duration("LINEAR_ACQUIRE_TIMEOUT", cfg.LinearAcquireTimeout)
+	cfg.LinearBreakerThreshold = e.integer("LINEAR_BREAKER_THRESHOLD", cfg.LinearBreakerThreshold)
+	cfg.LinearBreakerCooldown = e.duration("LINEAR_BREAKER_COOLDOWN", cfg.LinearBreakerCooldown)
+	cfg.LinearTeamRatePerMinute = e.integer("LINEAR_TEAM_RATE_LIMIT_PER_MINUTE", cfg.LinearTeamRatePerMinute)
+	cfg.LinearTeamRateBurst = e.integer("LINEAR_TEAM_RATE_LIMIT_BURST", cfg.LinearTeamRateBurst)
+	if cfg.LinearTeamRateBurst == 0 {
+		cfg.LinearTeamRateBurst = cfg.LinearTeamRatePerMinute
+	}
+	cfg.EgressHeaders = e.jsonMap("EGRESS_HEADERS", cfg.EgressHeaders)
+	cfg.LinearProxyURL = e.str("LINEAR_PROXY_URL", cfg.LinearProxyURL)
+	cfg.DryRun = e.boolean("DRY_RUN", cfg.DryRun)
+
+	cfg.FigmaWebhookPasscode = e.str("FIGMA_WEBHOOK_PASSCODE", cfg.FigmaWebhookPasscode)
+	cfg.FigmaAPIToken = e.str("FIGMA_API_TOKEN", cfg.FigmaAPIToken)
+	cfg.FigmaFileCacheTTL = e.duration("FIGMA_FILE_CACHE_TTL", cfg.FigmaFileCacheTTL)
+	cfg.FigmaEnrichTimeout = e.duration("FIGMA_ENRICH_TIMEOUT", cfg.FigmaEnrichTimeout)
+	cfg.AttachThumbnail = e.boolean("ATTACH_THUMBNAIL", cfg.AttachThumbnail)
+
+	cfg.AdminToken = e.str("ADMIN_TOKEN", cfg.AdminToken)
+	cfg.AdminCORSOrigins = e.list("ADMIN_CORS_ORIGINS", cfg.AdminCORSOrigins)
+	cfg.PublicURL = e.str("PUBLIC_URL", cfg.PublicURL)
+
+	cfg.SlackWebhookURL = e.str("SLACK_WEBHOOK_URL", cfg.SlackWebhookURL)
+
+	cfg.GitHubRepo = e.str("GITHUB_REPO", cfg.GitHubRepo)
+	cfg.GitHubToken = e.str("GITHUB_TOKEN", cfg.GitHubToken)
+	cfg.GitHubLabels = e.list("GITHUB_LABELS", cfg.GitHubLabels)
+
+	cfg.AllowedFileKeys = e.list("ALLOWED_FILE_KEYS", cfg.AllowedFileKeys)
+	cfg.FileTeamMap = e.jsonMap("FIGMA_FILE_TEAM_MAP", cfg.FileTeamMap)
+	cfg.RoutingMode = e.str("ROUTING_MODE", cfg.RoutingMode)
+	cfg.TeamTeamMap = e.jsonMap("FIGMA_TEAM_TEAM_MAP", cfg.TeamTeamMap)
+	cfg.UserAssigneeMap = e.jsonMap("FIGMA_USER_MAP", cfg.UserAssigneeMap)
+
+	cfg.TitleTemplate = e.str("ISSUE_TITLE_TEMPLATE", cfg.TitleTemplate)
+	cfg.TitlePrefix = e.str("ISSUE_TITLE_PREFIX", cfg.TitlePrefix)
+	cfg.TitleSuffix = e.str("ISSUE_TITLE_SUFFIX", cfg.TitleSuffix)
+	cfg.SourceFooter = e.boolean("ISSUE_SOURCE_FOOTER", cfg.SourceFooter)
+	cfg.DescriptionTemplate = e.str("ISSUE_DESCRIPTION_TEMPLATE", cfg.DescriptionTemplate)
+	cfg.MaxDescriptionChars = e.integer("MAX_DESCRIPTION_CHARS", cfg.MaxDescriptionChars)
+	cfg.CommentTriggerRegex = e.str("COMMENT_TRIGGER_REGEX", cfg.CommentTriggerRegex)
+
+	cfg.RateLimitPerMinute = e.integer("RATE_LIMIT_PER_MINUTE", cfg.RateLimitPerMinute)
+	// A bucket holds one minute's worth of events unless told otherwise.
+	cfg.RateLimitBurst = e.integer("RATE_LIMIT_BURST", cfg.RateLimitBurst)
+	if cfg.RateLimitBurst == 0 {
+		cfg.RateLimitBurst = cfg.RateLimitPerMinute
+	}
+
+	cfg.BatchWindow = e.duration("BATCH_WINDOW", cfg.BatchWindow)
+	cfg.DebounceInterval = e.duration("DEBOUNCE_INTERVAL", cfg.DebounceInterval)
+	cfg.PerFileCooldown = e.duration("PER_FILE_COOLDOWN", cfg.PerFileCooldown)
+	cfg.DigestMode = e.boolean("DIGEST_MODE", cfg.DigestMode)
+	cfg.DigestInterval = e.duration("DIGEST_INTERVAL", cfg.DigestInterval)
+	cfg.PublishSubIssues = e.boolean("PUBLISH_SUB_ISSUES", cfg.PublishSubIssues)
+	cfg.EscalationWindow = e.duration("ESCALATION_WINDOW", cfg.EscalationWindow)
+	cfg.EscalationThreshold = e.integer("ESCALATION_THRESHOLD", cfg.EscalationThreshold)
+	cfg.EscalationPriority = e.integer("ESCALATION_PRIORITY", cfg.EscalationPriority)
+	cfg.EventHistorySize = e.integer("EVENT_HISTORY_SIZE", cfg.EventHistorySize)
+	cfg.AuditLogPath = e.str("AUDIT_LOG_PATH", cfg.AuditLogPath)
+	cfg.DeadLetterPath = e.str("DEAD_LETTER_PATH", cfg.DeadLetterPath)
+
+	cfg.DedupTTL = e.duration("DEDUP_TTL", cfg.DedupTTL)
+	cfg.DedupBackend = e.str("DEDUP_BACKEND", cfg.DedupBackend)
+	cfg.DedupPath = e.str("DEDUP_PATH", cfg.DedupPath)
+	cfg.WorkerCount = e.integer("WORKER_COUNT", cfg.WorkerCount)
+	cfg.QueueSize = e.integer("QUEUE_SIZE", cfg.QueueSize)
+	cfg.QueueFullPolicy = e.str("QUEUE_FULL_POLICY", cfg.QueueFullPolicy)
+	cfg.ShutdownTimeout = e.duration("SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout)
+
+	// EVENT_LABELS sets label names for several event types at once, e.g.
+	// {"LIBRARY_PUBLISH":"design-system","FILE_COMMENT":"design-feedback"};
+	// values are comma-separated. <EVENT>_LABELS still wins over it.
+	eventLabels := e.jsonMap("EVENT_LABELS", nil)
+	for eventType := range eventLabels {
+		if _, ok := eventHandlers[eventType]; !ok {
+			e.fail(fmt.Errorf("EVENT_LABELS: unknown event type %q", eventType))
+		}
+	}
+
+	events := make(map[string]EventSettings)
+	for eventType := range eventHandlers {
+		settings := cfg.EventSettings[eventType]
+		settings.Priority = e.integer(eventType+"_PRIORITY", settings.Priority)
+		settings.LabelIDs = e.list(eventType+"_LABEL_IDS", settings.LabelIDs)
+		if labels, ok := eventLabels[eventType]; ok {
+			settings.LabelNames = splitList(labels)
+		}
+		settings.LabelNames = e.list(eventType+"_LABELS", settings.LabelNames)
+		settings.ProjectID = e.str(eventType+"_PROJECT_ID", settings.ProjectID)
+		settings.StateID = e.str(eventType+"_STATE_ID", settings.StateID)
+		settings.TemplateID = e.str(eventType+"_TEMPLATE_ID", settings.TemplateID)
+		events[eventType] = settings
+	}
+	for eventType := range cfg.EventSettings {
+		if _, ok := eventHandlers[eventType]; !ok {
+			e.fail(fmt.Errorf("events: unknown event type %q", eventType))
+		}
+	}
+	// ENABLED_EVENTS, when set, is the complete list of enabled event types
+	// and overrides the per-event enabled flags from the file.
+	if enabled := e.list("ENABLED_EVENTS", nil); enabled != nil {
+		for _, eventType := range enabled {
+			if _, ok := eventHandlers[eventType]; !ok {
+				e.fail(fmt.Errorf("ENABLED_EVENTS: unknown event type %q", eventType))
+			}
+		}
+		for eventType, settings := range events {
+			on := slices.Contains(enabled, eventType)
+			settings.Enabled = &on
+			events[eventType] = settings
+		}
+	}
+	cfg.EventSettings = events
+
+	if err := e.err(); err != nil {
+		return nil, err
+	}
+	if err := applyFlags(cfg, args); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	var errs []error
+	require := func(key, value string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	}
+
+	if len(cfg.webhookPasscodes()) == 0 {
+		errs = append(errs, fmt.Errorf("FIGMA_WEBHOOK_PASSCODE is required"))
+	}
+
+	// Dry runs never reach the target, so they can be exercised without
+	// credentials.
+	switch cfg.Target {
+	case "linear":
+		if cfg.LinearTeamID == "" && cfg.LinearTeamName == "" {
+			errs = append(errs, fmt.Errorf("LINEAR_TEAM_ID or LINEAR_TEAM_NAME is required"))
+		}
+		// Names are resolved through the API even in a dry run.
+		if !cfg.DryRun || cfg.usesLinearNames() {
+			require("LINEAR_API_KEY", cfg.LinearAPIKey)
+		}
+	case "github":
+		require("GITHUB_REPO", cfg.GitHubRepo)
+		if !cfg.DryRun {
+			require("GITHUB_TOKEN", cfg.GitHubToken)
+		}
+		if cfg.GitHubRepo != "" && strings.Count(cfg.GitHubRepo, "/") != 1 {
+			errs = append(errs, fmt.Errorf("GITHUB_REPO must look like owner/name, got %q", cfg.GitHubRepo))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("TARGET must be linear or github, got %q", cfg.Target))
+	}
+
+	var err error
+	if cfg.titleTemplate, err = parseIssueTemplate("ISSUE_TITLE_TEMPLATE", cfg.TitleTemplate); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.descriptionTemplate, err = parseIssueTemplate("ISSUE_DESCRIPTION_TEMPLATE", cfg.DescriptionTemplate); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.CommentTriggerRegex != "" {
+		if cfg.commentTrigger, err = regexp.Compile(cfg.CommentTriggerRegex); err != nil {
+			errs = append(errs, fmt.Errorf("COMMENT_TRIGGER_REGEX is invalid: %w", err))
+		}
+	}
+
+	for eventType, settings := range cfg.EventSettings {
+		if settings.Priority < 0 || settings.Priority > 4 {
+			errs = append(errs, fmt.Errorf("%s_PRIORITY must be between 0 and 4, got %d", eventType, settings.Priority))
+		}
+	}
+
+	if cfg.logLevel, err = parseLogLevel(cfg.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.MaxBodyBytes < 1 {
+		errs = append(errs, fmt.Errorf("MAX_BODY_BYTES must be positive, got %d", cfg.MaxBodyBytes))
+	}
+	if cfg.LinearTeamRatePerMinute < 0 {
+		errs = append(errs, fmt.Errorf("LINEAR_TEAM_RATE_LIMIT_PER_MINUTE must not be negative, got %d", cfg.LinearTeamRatePerMinute))
+	}
+	if cfg.RateLimitPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_PER_MINUTE must not be negative, got %d", cfg.RateLimitPerMinute))
+	}
+	if cfg.DedupBackend != "memory" && cfg.DedupBackend != "bolt" {
+		errs = append(errs, fmt.Errorf("DEDUP_BACKEND must be memory or bolt, got %q", cfg.DedupBackend))
+	}
+	switch cfg.LinearMode {
+	case "create":
+	case "comment":
+		require("LINEAR_COMMENT_ISSUE_ID", cfg.LinearCommentIssueID)
+	default:
+		errs = append(errs, fmt.Errorf("LINEAR_MODE must be create or comment, got %q", cfg.LinearMode))
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	} else if cfg.tlsEnabled() {
+		if err := checkReadable(cfg.TLSCertFile); err != nil {
+			errs = append(errs, fmt.Errorf("TLS_CERT_FILE: %w", err))
+		}
+		if err := checkReadable(cfg.TLSKeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("TLS_KEY_FILE: %w", err))
+		}
+	}
+	for name, value := range cfg.EgressHeaders {
+		if name == "" || strings.ContainsAny(name, " :\r\n") || strings.ContainsAny(value, "\r\n") {
+			errs = append(errs, fmt.Errorf("EGRESS_HEADERS: invalid header %q", name))
+		}
+	}
+	if cfg.EscalationWindow > 0 {
+		if cfg.EscalationPriority < 1 || cfg.EscalationPriority > 4 {
+			errs = append(errs, fmt.Errorf("ESCALATION_PRIORITY must be between 1 and 4, got %d", cfg.EscalationPriority))
+		}
+		if cfg.EscalationThreshold < 1 {
+			errs = append(errs, fmt.Errorf("ESCALATION_THRESHOLD must be at least 1, got %d", cfg.EscalationThreshold))
+		}
+	}
+	if cfg.RoutingMode != "file" && cfg.RoutingMode != "team" {
+		errs = append(errs, fmt.Errorf("ROUTING_MODE must be file or team, got %q", cfg.RoutingMode))
+	}
+	if cfg.QueueFullPolicy != "drop" && cfg.QueueFullPolicy != "block" {
+		errs = append(errs, fmt.Errorf("QUEUE_FULL_POLICY must be drop or block, got %q", cfg.QueueFullPolicy))
+	}
+	if u, err := url.Parse(cfg.LinearAPIURL); err != nil || !u.IsAbs() || u.Host == "" {
+		errs = append(errs, fmt.Errorf("LINEAR_API_URL must be an absolute URL, got %q", cfg.LinearAPIURL))
+	}
+	if cfg.LinearProxyURL != "" {
+		if u, err := url.Parse(cfg.LinearProxyURL); err != nil || !u.IsAbs() || u.Host == "" {
+			// The URL may embed proxy credentials, so it isn't echoed back.
+			errs = append(errs, errors.New("LINEAR_PROXY_URL must be an absolute URL"))
+		}
+	}
+	for _, origin := range cfg.AdminCORSOrigins {
+		// Browsers send Origin as scheme://host[:port] with nothing after it.
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || !u.IsAbs() || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "") {
+			errs = append(errs, fmt.Errorf("ADMIN_CORS_ORIGINS: invalid origin %q", origin))
+		}
+	}
+	if !slices.Contains([]string{"raw", "bearer", "auto"}, cfg.LinearAuthScheme) {
+		errs = append(errs, fmt.Errorf("LINEAR_AUTH_SCHEME must be raw, bearer or auto, got %q", cfg.LinearAuthScheme))
+	}
+	seen := make(map[string]bool)
+	for _, ec := range cfg.Endpoints {
+		if !validEndpointName(ec.Name) {
+			errs = append(errs, fmt.Errorf("endpoints: name %q must be non-empty and use only letters, digits, - and _", ec.Name))
+		}
+		if seen[ec.Name] {
+			errs = append(errs, fmt.Errorf("endpoints: duplicate name %q", ec.Name))
+		}
+		seen[ec.Name] = true
+		if cfg.Target == "linear" && cfg.LinearTeamName == "" && cmp.Or(ec.LinearTeamID, cfg.LinearTeamID) == "" {
+			errs = append(errs, fmt.Errorf("endpoints: %s needs linear_team_id", ec.Name))
+		}
+		if cfg.Target == "linear" && !cfg.DryRun && cmp.Or(ec.LinearAPIKey, cfg.LinearAPIKey) == "" {
+			errs = append(errs, fmt.Errorf("endpoints: %s needs linear_api_key", ec.Name))
+		}
+	}
+
+	if cfg.DigestMode && cfg.DigestInterval <= 0 {
+		errs = append(errs, fmt.Errorf("DIGEST_INTERVAL must be positive, got %s", cfg.DigestInterval))
+	}
+	if cfg.PerFileCooldown < 0 {
+		errs = append(errs, fmt.Errorf("PER_FILE_COOLDOWN must not be negative, got %s", cfg.PerFileCooldown))
+	}
+	if cfg.DebounceInterval < 0 {
+		errs = append(errs, fmt.Errorf("DEBOUNCE_INTERVAL must not be negative, got %s", cfg.DebounceInterval))
+	}
+	if cfg.FigmaEnrichTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("FIGMA_ENRICH_TIMEOUT must be positive, got %s", cfg.FigmaEnrichTimeout))
+	}
+	if cfg.AttachThumbnail && cfg.FigmaAPIToken == "" {
+		errs = append(errs, fmt.Errorf("ATTACH_THUMBNAIL requires FIGMA_API_TOKEN"))
+	}
+	if cfg.MaxDescriptionChars < len([]rune(truncatedSuffix)) {
+		errs = append(errs, fmt.Errorf("MAX_DESCRIPTION_CHARS must be at least %d, got %d", len([]rune(truncatedSuffix)), cfg.MaxDescriptionChars))
+	}
+	if cfg.EventHistorySize < 0 {
+		errs = append(errs, fmt.Errorf("EVENT_HISTORY_SIZE must not be negative, got %d", cfg.EventHistorySize))
+	}
+	if cfg.LinearMaxConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("LINEAR_MAX_CONNS_PER_HOST must not be negative, got %d", cfg.LinearMaxConnsPerHost))
+	}
+	if cfg.LinearMaxIdleConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("LINEAR_MAX_IDLE_CONNS_PER_HOST must not be negative, got %d", cfg.LinearMaxIdleConnsPerHost))
+	}
+	if cfg.LinearIdleConnTimeout < 0 {
+		errs = append(errs, fmt.Errorf("LINEAR_IDLE_CONN_TIMEOUT must not be negative, got %s", cfg.LinearIdleConnTimeout))
+	}
+	if cfg.LinearMaxConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("LINEAR_MAX_CONCURRENCY must be at least 1, got %d", cfg.LinearMaxConcurrency))
+	}
+	if cfg.WorkerCount < 1 {
+		errs = append(errs, fmt.Errorf("WORKER_COUNT must be at least 1, got %d", cfg.WorkerCount))
+	}
+	if cfg.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("QUEUE_SIZE must not be negative, got %d", cfg.QueueSize))
+	}
+
+	return errors.Join(errs...)
+}
+
+// effective returns the resolved config keyed by YAML name, with secrets
+// masked, for logging at startup.
+func (cfg *Config) effective() map[string]any {
+	c := *cfg
+	for _, secret := range []*string{&c.LinearAPIKey, &c.FigmaWebhookPasscode, &c.FigmaAPIToken, &c.AdminToken, &c.GitHubToken, &c.SlackWebhookURL, &c.LinearProxyURL} {
+		*secret = redact(*secret)
+	}
+	c.Endpoints = slices.Clone(cfg.Endpoints)
+	for i := range c.Endpoints {
+		c.Endpoints[i].LinearAPIKey = redact(c.Endpoints[i].LinearAPIKey)
+		c.Endpoints[i].FigmaWebhookPasscode = redact(c.Endpoints[i].FigmaWebhookPasscode)
+	}
+	if len(c.EgressHeaders) > 0 {
+		c.EgressHeaders = make(map[string]string, len(cfg.EgressHeaders))
+		for name, value := range cfg.EgressHeaders {
+			c.EgressHeaders[name] = redact(value)
+		}
+	}
+
+	out := make(map[string]any)
+	b, err := yaml.Marshal(&c)
+	if err == nil {
+		err = yaml.Unmarshal(b, &out)
+	}
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return out
+}
+
+// LogValue keeps secrets out of the logs if the config itself is ever
+// passed to a logger.
+func (cfg *Config) LogValue() slog.Value {
+	return slog.AnyValue(cfg.effective())
+}
+
+// fileAllowed reports whether events for fileKey should be processed. An
+// empty allowlist allows every file.
+func (cfg *Config) fileAllowed(fileKey string) bool {
+	return len(cfg.AllowedFileKeys) == 0 || slices.Contains(cfg.AllowedFileKeys, fileKey)
+}
+
+func (cfg *Config) tlsEnabled() bool {
+	return cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+}
+
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (cfg *Config) eventEnabled(eventType string) bool {
+	enabled := cfg.EventSettings[eventType].Enabled
+	if enabled == nil {
+		return !optInEvents[eventType]
+	}
+	return *enabled
+}
+
+// usesLinearNames reports whether any team or label is configured by name
+// and so needs resolving through the Linear API.
+func (cfg *Config) usesLinearNames() bool {
+	if cfg.LinearTeamID == "" && cfg.LinearTeamName != "" {
+		return true
+	}
+	for _, settings := range cfg.EventSettings {
+		if len(settings.LabelNames) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *Config) commentTriggered(text string) bool {
+	return cfg.commentTrigger == nil || cfg.commentTrigger.MatchString(text)
+}
+
+// webhookPasscodes splits FIGMA_WEBHOOK_PASSCODE into the passcodes accepted
+// for signature verification. The first entry is the current passcode; any
+// others are older ones still honoured during rotation.
+func (cfg *Config) webhookPasscodes() []string {
+	var passcodes []string
+	for _, p := range strings.Split(cfg.FigmaWebhookPasscode, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			passcodes = append(passcodes, p)
+		}
+	}
+	return passcodes
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ConfigProvider supplies the YAML (or JSON) config document that is
+// layered over the built-in defaults, before env vars and flags.
+type ConfigProvider interface {
+	// Load returns the document, or nil when there is none to apply, and
+	// a description of where it came from for error messages.
+	Load(ctx context.Context) (doc []byte, source string, err error)
+}
+
+// consulFetchTimeout bounds the startup fetch so an unreachable Consul
+// fails fast instead of hanging the process.
+const consulFetchTimeout = 10 * time.Second
+
+// configProvider picks the provider named by CONFIG_BACKEND: file, the
+// default, or consul.
+func configProvider() (ConfigProvider, error) {
+	switch backend := os.Getenv("CONFIG_BACKEND"); backend {
+	case "", "file":
+		path := os.Getenv("RELAY_CONFIG")
+		return fileConfigProvider{path: cmp.Or(path, defaultConfigPath), explicit: path != ""}, nil
+	case "consul":
+		addr, key := os.Getenv("CONSUL_ADDR"), strings.Trim(os.Getenv("CONSUL_KEY"), "/")
+		if addr == "" || key == "" {
+			return nil, errors.New("CONFIG_BACKEND=consul requires CONSUL_ADDR and CONSUL_KEY")
+		}
+		if !strings.Contains(addr, "://") {
+			addr = "http://" + addr
+		}
+		if u, err := url.Parse(addr); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("CONSUL_ADDR must be a host:port or URL, got %q", os.Getenv("CONSUL_ADDR"))
+		}
+		return consulConfigProvider{
+			addr:   strings.TrimRight(addr, "/"),
+			key:    key,
+			token:  os.Getenv("CONSUL_HTTP_TOKEN"),
+			client: &http.Client{Timeout: consulFetchTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("CONFIG_BACKEND must be file or consul, got %q", backend)
+	}
+}
+
+// fileConfigProvider reads the config file. A missing file is only an
+// error when RELAY_CONFIG points at it explicitly.
+type fileConfigProvider struct {
+	path     string
+	explicit bool
+}
+
+func (p fileConfigProvider) Load(context.Context) ([]byte, string, error) {
+	source := "config file " + p.path
+	doc, err := os.ReadFile(p.path)
+	if errors.Is(err, fs.ErrNotExist) && !p.explicit {
+		return nil, source, nil
+	}
+	if err != nil {
+		return nil, source, fmt.Errorf("failed to open config file: %w", err)
+	}
+	return doc, source, nil
+}
+
+// consulConfigProvider reads the config document stored at a Consul KV
+// key. CONSUL_HTTP_TOKEN, when set, is sent as the ACL token.
+type consulConfigProvider struct {
+	addr   string
+	key    string
+	token  string
+	client *http.Client
+}
+
+func (p consulConfigProvider) Load(ctx context.Context) ([]byte, string, error) {
+	source := "Consul key " + p.key
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+"/v1/kv/"+p.key+"?raw", nil)
+	if err != nil {
+		return nil, source, err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Consul-Token", p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, source, fmt.Errorf("failed to fetch config from Consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	doc, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, source, fmt.Errorf("failed to read config from Consul: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, source, fmt.Errorf("%s does not exist", source)
+	case resp.StatusCode != http.StatusOK:
+		return nil, source, fmt.Errorf("failed to fetch config from Consul: %s: %s", resp.Status, strings.TrimSpace(string(doc)))
+	}
+	return doc, source, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestConfigLogValueRedactsSecrets(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.LinearAPIKey = "lin_api_0123456789abcdefSECRET"
+	cfg.FigmaWebhookPasscode = "old-passcode-secret,new-passcode-secret"
+	cfg.FigmaAPIToken = "figd_figma-token-secret"
+	cfg.AdminToken = "admin-token-secret"
+	cfg.GitHubToken = "ghp_github-token-secret"
+	cfg.SlackWebhookURL = "https://hooks.slack.com/services/T000/B000/slack-secret"
+	cfg.EgressHeaders = map[string]string{"X-Egress-Auth": "egress-header-secret"}
+	cfg.Endpoints = []EndpointConfig{{
+		Name:                 "marketing",
+		LinearAPIKey:         "lin_api_endpoint-key-secret",
+		FigmaWebhookPasscode: "endpoint-passcode-secret",
+	}}
+	secrets := []string{
+		cfg.LinearAPIKey,
+		"old-passcode-secret",
+		"new-passcode-secret",
+		cfg.FigmaAPIToken,
+		cfg.AdminToken,
+		cfg.GitHubToken,
+		cfg.SlackWebhookURL,
+		"egress-header-secret",
+		"lin_api_endpoint-key-secret",
+		"endpoint-passcode-secret",
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("Effective configuration", "config", cfg, "linear", cfg.linear())
+	logged := buf.String()
+
+	for _, secret := range secrets {
+		if strings.Contains(logged, secret) {
+			t.Errorf("log line contains secret %q:\n%s", secret, logged)
+		}
+	}
+
+	// The line must still be usable: valid JSON with the masked key in it.
+	var line struct {
+		Config map[string]any `json:"config"`
+		Linear map[string]any `json:"linear"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line isn't JSON: %v", err)
+	}
+	if got, want := line.Config["linear_api_key"], redact(cfg.LinearAPIKey); got != want {
+		t.Errorf("config.linear_api_key = %v, want %q", got, want)
+	}
+	if got, want := line.Linear["api_key"], redact(cfg.LinearAPIKey); got != want {
+		t.Errorf("linear.api_key = %v, want %q", got, want)
+	}
+	if cfg.LinearAPIKey != "lin_api_0123456789abcdefSECRET" || cfg.Endpoints[0].LinearAPIKey != "lin_api_endpoint-key-secret" {
+		t.Error("logging the config modified it")
+	}
+}
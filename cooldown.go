@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// issueCooldown remembers the last issue created for each file and event
+// type, so further events inside the cooldown comment on that issue
+// instead of filing another.
+type issueCooldown struct {
+	mu     sync.Mutex
+	period time.Duration
+	issues map[string]*cooldownIssue
+}
+
+type cooldownIssue struct {
+	id      string
+	created time.Time
+	// ready is closed once the event that claimed the entry has finished
+	// creating its issue, successfully or not.
+	ready chan struct{}
+}
+
+func newIssueCooldown(period time.Duration) *issueCooldown {
+	c := &issueCooldown{period: period, issues: make(map[string]*cooldownIssue)}
+	go runCleanup(period, c.cleanup)
+	return c
+}
+
+func cooldownKey(fileKey, eventType string) string {
+	return eventType + "|" + fileKey
+}
+
+// expired reports whether a created issue has left its cooldown. Entries
+// still being created never expire.
+func (e *cooldownIssue) expired(period time.Duration, now time.Time) bool {
+	return e.id != "" && now.Sub(e.created) >= period
+}
+
+// claim returns the issue still in cooldown for key, if there is one.
+// Otherwise it reserves key for the caller, who must then call release.
+// Concurrent events for a reserved key wait for the first to finish, so at
+// most one issue is created per cooldown.
+func (c *issueCooldown) claim(ctx context.Context, key string) (issueID string, active bool, err error) {
+	for {
+		c.mu.Lock()
+		entry, ok := c.issues[key]
+		switch {
+		case !ok || entry.expired(c.period, time.Now()):
+			c.issues[key] = &cooldownIssue{ready: make(chan struct{})}
+			c.mu.Unlock()
+			return "", false, nil
+		case entry.id != "":
+			c.mu.Unlock()
+			return entry.id, true, nil
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-entry.ready:
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		}
+	}
+}
+
+// release records the issue created for a claimed key and starts its
+// cooldown. An empty issueID means creation failed, and the next event may
+// try again.
+func (c *issueCooldown) release(key, issueID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.issues[key]
+	if issueID == "" {
+		delete(c.issues, key)
+	} else {
+		entry.id = issueID
+		entry.created = time.Now()
+	}
+	close(entry.ready)
+}
+
+func (c *issueCooldown) cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.issues {
+		if entry.expired(c.period, now) {
+			delete(c.issues, key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// corsMaxAge is how long browsers may cache a preflight response.
+const corsMaxAge = 10 * 60
+
+// corsPolicy lets browser apps on the listed origins call the admin API.
+// A nil policy adds no CORS headers, so cross-origin calls stay blocked.
+type corsPolicy struct {
+	origins []string
+}
+
+func newCORSPolicy(origins []string) *corsPolicy {
+	if len(origins) == 0 {
+		return nil
+	}
+	return &corsPolicy{origins: origins}
+}
+
+func (p *corsPolicy) allowed(origin string) bool {
+	return origin != "" && (slices.Contains(p.origins, "*") || slices.Contains(p.origins, origin))
+}
+
+// allow adds the CORS response headers for requests from an allowed
+// origin. Requests from anywhere else are served unchanged and the browser
+// withholds the response.
+func (p *corsPolicy) allow(next http.Handler) http.Handler {
+	if p == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); p.allowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight answers a browser's OPTIONS check for a route that accepts
+// method. Admin calls authenticate with a bearer token, so credentials
+// (cookies) are never allowed.
+func (p *corsPolicy) preflight(method string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		origin := r.Header.Get("Origin")
+		if !p.allowed(origin) {
+			loggerFrom(r.Context()).Warn("Rejected CORS preflight from origin", "origin", origin)
+			writeJSONError(w, http.StatusForbidden, errCodeOriginNotAllowed, "Origin not allowed")
+			return
+		}
+		if requested := r.Header.Get("Access-Control-Request-Method"); requested != method {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", method)
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{"Authorization", "Content-Type", idempotencyKeyHeader}, ", "))
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// fileDebouncer holds the most recent FILE_UPDATE per file and only hands
+// it on once the file has been quiet for the interval, so a burst of saves
+// while someone is editing produces one issue for the final state.
+type fileDebouncer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	pending  map[string]*pendingBatch
+	closed   bool
+	flush    func(issueJob)
+}
+
+func newFileDebouncer(interval time.Duration, flush func(issueJob)) *fileDebouncer {
+	return &fileDebouncer{
+		interval: interval,
+		pending:  make(map[string]*pendingBatch),
+		flush:    flush,
+	}
+}
+
+// Add reports whether the job is being held. Each new event for a file
+// replaces the held one and restarts the quiet period. It returns false
+// once the debouncer has been closed, leaving the caller to handle the job
+// directly.
+func (d *fileDebouncer) Add(job issueJob) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return false
+	}
+
+	key := job.endpoint.path + "|" + job.event.FileKey
+	held, ok := d.pending[key]
+	if !ok {
+		held = &pendingBatch{job: job}
+		held.timer = time.AfterFunc(d.interval, func() { d.expire(key, held) })
+		d.pending[key] = held
+		job.logger.Info("Debouncing file update", "interval", d.interval.String())
+		return true
+	}
+
+	// Keep the superseded events' dedup keys so redeliveries of them are
+	// still recognised once the surviving event is processed.
+	keys := append(slices.Clone(held.job.dedupKeys), job.dedupKeys...)
+	job.logger.Info("Superseded debounced file update", "debounced_events", len(keys))
+	job.logger = held.job.logger
+	job.dedupKeys = keys
+
+	// Replacing the entry rather than resetting its timer means a timer
+	// that already fired and is waiting on the lock finds itself stale.
+	held.timer.Stop()
+	next := &pendingBatch{job: job}
+	next.timer = time.AfterFunc(d.interval, func() { d.expire(key, next) })
+	d.pending[key] = next
+	return true
+}
+
+func (d *fileDebouncer) expire(key string, held *pendingBatch) {
+	d.mu.Lock()
+	// A newer event or a flush during shutdown may already have taken
+	// this job.
+	if d.pending[key] != held {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	d.mu.Unlock()
+
+	d.flush(held.job)
+}
+
+// Close stops accepting events and flushes every held update immediately
+// rather than waiting for its quiet period to end.
+func (d *fileDebouncer) Close() {
+	d.mu.Lock()
+	d.closed = true
+	held := d.pending
+	d.pending = make(map[string]*pendingBatch)
+	d.mu.Unlock()
+
+	if len(held) > 0 {
+		slog.Info("Flushing debounced file updates", "files", len(held))
+	}
+	for _, h := range held {
+		h.timer.Stop()
+		d.flush(h.job)
+	}
+}
+
+func (rl *relay) flushDebounced(job issueJob) {
+	job.logger.Info("Flushing debounced file update", "debounced_events", len(job.dedupKeys))
+	if !rl.queue.Enqueue(context.Background(), job) {
+		job.logger.Error("Work queue is full or closed, dropping debounced file update")
+		rl.dropJob(job)
+	}
+}
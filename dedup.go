@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DedupStore remembers which events have already produced an issue, so
+// redelivered webhooks can be acknowledged without creating another one.
+type DedupStore interface {
+	Seen(key string) (issueID string, ok bool)
+	Record(key, issueID string) error
+}
+
+func dedupKey(webhook FigmaWebhook) string {
+	return webhook.FileKey + "|" + webhook.EventType + "|" + webhook.Timestamp
+}
+
+// Callers other than Figma can send an Idempotency-Key header to choose the
+// dedup key themselves instead of relying on the event fingerprint.
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotencyKey returns the request's Idempotency-Key, namespaced so it
+// can't collide with a computed fingerprint, or "" when none was sent.
+func idempotencyKey(r *http.Request) (string, error) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return "", nil
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return "", fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLen)
+	}
+	return "idempotency|" + key, nil
+}
+
+// pendingKeys holds the dedup keys of events that have been accepted but
+// not yet delivered. The store only learns of an event once its issue
+// exists, so without this a redelivery arriving while the first is still
+// queued would create a second issue. Reservations are kept in memory: a
+// job lost to a restart should be retried by the next delivery.
+type pendingKeys struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func newPendingKeys() *pendingKeys {
+	return &pendingKeys{keys: make(map[string]struct{})}
+}
+
+// reserve claims key for a new job. It returns false if another job
+// already holds it.
+func (p *pendingKeys) reserve(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.keys[key]; ok {
+		return false
+	}
+	p.keys[key] = struct{}{}
+	return true
+}
+
+// release drops the reservations once a job has finished. A delivered job
+// must have recorded its keys in the store first, so there's no window in
+// which a redelivery finds neither.
+func (p *pendingKeys) release(keys ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, key := range keys {
+		delete(p.keys, key)
+	}
+}
+
+func newDedupStore(cfg *Config) (DedupStore, error) {
+	switch cfg.DedupBackend {
+	case "memory":
+		return newMemoryDedupStore(cfg.DedupTTL), nil
+	case "bolt":
+		return newBoltDedupStore(cfg.DedupPath, cfg.DedupTTL)
+	default:
+		return nil, fmt.Errorf("unknown dedup backend %q", cfg.DedupBackend)
+	}
+}
+
+type dedupEntry struct {
+	IssueID   string    `json:"issue_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type memoryDedupStore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]dedupEntry
+}
+
+func newMemoryDedupStore(ttl time.Duration) *memoryDedupStore {
+	s := &memoryDedupStore{
+		ttl:  ttl,
+		seen: make(map[string]dedupEntry),
+	}
+	go runCleanup(ttl, s.cleanup)
+	return s
+}
+
+func (s *memoryDedupStore) Seen(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.seen[key]
+	if !ok || time.Now().After(entry.ExpiresAt) {
+		return "", false
+	}
+	return entry.IssueID, true
+}
+
+func (s *memoryDedupStore) Record(key, issueID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seen[key] = dedupEntry{IssueID: issueID, ExpiresAt: time.Now().Add(s.ttl)}
+	return nil
+}
+
+func (s *memoryDedupStore) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range s.seen {
+		if now.After(entry.ExpiresAt) {
+			delete(s.seen, key)
+		}
+	}
+}
+
+var dedupBucket = []byte("dedup")
+
+type boltDedupStore struct {
+	db  *bolt.DB
+	ttl time.Duration
+
+	// stop ends the cleanup goroutine, which closes done on exit so Close
+	// never closes the database under a running cleanup.
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newBoltDedupStore(path string, ttl time.Duration) (*boltDedupStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dedup store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(dedupBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize dedup store %s: %w", path, err)
+	}
+
+	s := &boltDedupStore{db: db, ttl: ttl, stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		runCleanupUntil(s.stop, ttl, s.cleanup)
+	}()
+	return s, nil
+}
+
+func (s *boltDedupStore) Seen(key string) (string, bool) {
+	var entry dedupEntry
+	var found bool
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(dedupBucket).Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &entry)
+	})
+	if err != nil {
+		slog.Warn("Failed to read dedup store", "key", key, "error", err)
+		return "", false
+	}
+
+	if !found || time.Now().After(entry.ExpiresAt) {
+		return "", false
+	}
+	return entry.IssueID, true
+}
+
+func (s *boltDedupStore) Record(key, issueID string) error {
+	v, err := json.Marshal(dedupEntry{IssueID: issueID, ExpiresAt: time.Now().Add(s.ttl)})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(dedupBucket).Put([]byte(key), v)
+	})
+}
+
+func (s *boltDedupStore) cleanup() {
+	now := time.Now()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(dedupBucket)
+		// Collect the expired keys and delete them after the scan rather
+		// than mutating the bucket under the cursor.
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var entry dedupEntry
+			if err := json.Unmarshal(v, &entry); err != nil || now.After(entry.ExpiresAt) {
+				expired = append(expired, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Warn("Failed to clean up dedup store", "error", err)
+	}
+}
+
+func (s *boltDedupStore) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.db.Close()
+}
+
+func runCleanup(interval time.Duration, cleanup func()) {
+	runCleanupUntil(nil, interval, cleanup)
+}
+
+// runCleanupUntil is runCleanup for owners that stop it: it returns once
+// stop is closed.
+func runCleanupUntil(stop <-chan struct{}, interval time.Duration, cleanup func()) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cleanup()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltDedupStoreCleanup(t *testing.T) {
+	s, err := newBoltDedupStore(filepath.Join(t.TempDir(), "dedup.db"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Runs of adjacent expired keys spread over several pages.
+	now := time.Now()
+	var want []string
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for i := range 1000 {
+			expires := now.Add(-time.Minute)
+			key := fmt.Sprintf("key-%04d", i)
+			if i%5 == 4 {
+				expires = now.Add(time.Hour)
+				want = append(want, key)
+			}
+			v, err := json.Marshal(dedupEntry{IssueID: fmt.Sprintf("issue-%d", i), ExpiresAt: expires})
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(dedupBucket).Put([]byte(key), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.cleanup()
+
+	var left []string
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(dedupBucket).ForEach(func(k, _ []byte) error {
+			left = append(left, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(left, want) {
+		t.Errorf("%d keys left after cleanup, want the %d unexpired ones", len(left), len(want))
+	}
+}
+
+func TestBoltDedupStoreCloseStopsCleanup(t *testing.T) {
+	s, err := newBoltDedupStore(filepath.Join(t.TempDir(), "dedup.db"), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-s.done:
+	default:
+		t.Fatal("cleanup goroutine still running after Close")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventDigest collects accepted events per endpoint instead of filing an
+// issue for each, and hands each endpoint's batch to deliver once per
+// interval so it gets one summary issue.
+type eventDigest struct {
+	mu      sync.Mutex
+	pending map[*endpoint][]issueJob
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+	deliver func(context.Context, *endpoint, []issueJob)
+}
+
+func newEventDigest(interval time.Duration, deliver func(context.Context, *endpoint, []issueJob)) *eventDigest {
+	d := &eventDigest{
+		pending: make(map[*endpoint][]issueJob),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		deliver: deliver,
+	}
+	go d.run(interval)
+	return d
+}
+
+func (d *eventDigest) run(interval time.Duration) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.flush(context.Background())
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// Add reports whether the job was taken into the digest. It returns false
+// once the digest has been closed, leaving the caller to handle the job
+// directly.
+func (d *eventDigest) Add(job issueJob) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return false
+	}
+
+	d.pending[job.endpoint] = append(d.pending[job.endpoint], job)
+	job.logger.Info("Added event to digest", "digest_events", len(d.pending[job.endpoint]))
+	return true
+}
+
+func (d *eventDigest) flush(ctx context.Context) {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[*endpoint][]issueJob)
+	d.mu.Unlock()
+
+	for ep, jobs := range pending {
+		d.deliver(ctx, ep, jobs)
+	}
+}
+
+// Close stops the schedule and delivers whatever has accumulated, so a
+// restart doesn't lose a partial digest.
+func (d *eventDigest) Close(ctx context.Context) {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	close(d.stop)
+	<-d.done
+
+	d.mu.Lock()
+	n := len(d.pending)
+	d.mu.Unlock()
+	if n > 0 {
+		slog.Info("Flushing pending digest", "endpoints", n)
+	}
+	d.flush(ctx)
+}
+
+// deliverDigest files one issue summarising jobs and records every event
+// in it against that issue, or as failed if delivery didn't succeed.
+func (rl *relay) deliverDigest(ctx context.Context, ep *endpoint, jobs []issueJob) {
+	logger := slog.Default().With("endpoint", ep.name, "digest_events", len(jobs))
+	ctx = withLogger(ctx, logger)
+
+	event := FigmaWebhook{EventType: "DIGEST", Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	title, description := digestIssue(jobs)
+	title = wrapTitle(title, rl.cfg.TitlePrefix, rl.cfg.TitleSuffix)
+	description = rl.finishDescription(ctx, event, description)
+
+	issueID, err := notifyAll(ctx, ep.notifiers, event, title, description)
+	if err != nil {
+		logger.Error("Failed to deliver digest", "error", err)
+	}
+
+	for _, job := range jobs {
+		if issueID != "" {
+			for _, key := range job.dedupKeys {
+				if err := rl.dedup.Record(key, issueID); err != nil {
+					job.logger.Error("Failed to record processed event", "error", err)
+				}
+			}
+		}
+		rl.pending.release(job.dedupKeys...)
+		switch {
+		case err == nil:
+			rl.recordEvent(job.event, "processed", issueID, nil)
+		case issueID != "":
+			rl.recordEvent(job.event, "partial", issueID, err)
+		default:
+			stats.failures.Add(1)
+			rl.recordEvent(job.event, "failed", "", err)
+			rl.recordFailure(ctx, job, err)
+		}
+	}
+	if err == nil {
+		logger.Info("Delivered digest", "issue_id", issueID)
+	}
+}
+
+// digestIssue lists each event's usual issue title with a link to its
+// file, oldest first.
+func digestIssue(jobs []issueJob) (string, string) {
+	counts := make(map[string]int)
+	var sb strings.Builder
+	for _, job := range jobs {
+		counts[job.event.EventType]++
+		title, _ := eventHandlers[job.event.EventType](job.event)
+		fmt.Fprintf(&sb, "- %s", escapeMarkdown(title))
+		if job.event.FileKey != "" {
+			fmt.Fprintf(&sb, " ([open in Figma](%s))", figmaFileURL(job.event.FileKey))
+		}
+		if job.event.Timestamp != "" {
+			fmt.Fprintf(&sb, " at %s", job.event.Timestamp)
+		}
+		sb.WriteString("\n")
+	}
+
+	eventTypes := make([]string, 0, len(counts))
+	for eventType := range counts {
+		eventTypes = append(eventTypes, eventType)
+	}
+	slices.Sort(eventTypes)
+	summary := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		summary[i] = fmt.Sprintf("%d %s", counts[eventType], eventType)
+	}
+
+	title := fmt.Sprintf("Figma Digest: %d events", len(jobs))
+	if len(jobs) == 1 {
+		title = "Figma Digest: 1 event"
+	}
+	description := fmt.Sprintf("Figma events received since the last digest: %s.\n\n%s", strings.Join(summary, ", "), sb.String())
+	return title, description
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// recordingNotifier keeps every notification it's given.
+type recordingNotifier struct {
+	mu    sync.Mutex
+	calls []recordedNotification
+}
+
+type recordedNotification struct {
+	event              FigmaWebhook
+	title, description string
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, event FigmaWebhook, title, description string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls = append(n.calls, recordedNotification{event, title, description})
+	return nil
+}
+
+func newDigestTestRelay(cfg *Config) *relay {
+	return &relay{
+		cfg:     cfg,
+		dedup:   newMemoryDedupStore(time.Minute),
+		pending: newPendingKeys(),
+		history: newEventHistory(cfg.EventHistorySize),
+		stream:  newEventBroadcaster(),
+	}
+}
+
+func TestDeliverDigestFormatsLikeIssues(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.TitlePrefix = "[Figma]"
+	cfg.TitleSuffix = "(design)"
+	cfg.SourceFooter = true
+	cfg.MaxDescriptionChars = 200
+	rl := newDigestTestRelay(cfg)
+
+	notifier := &recordingNotifier{}
+	ep := &endpoint{name: "default", cfg: cfg, notifiers: []Notifier{notifier}}
+	var jobs []issueJob
+	for i := range 20 {
+		jobs = append(jobs, issueJob{
+			logger:   slog.Default(),
+			endpoint: ep,
+			event:    FigmaWebhook{EventType: "FILE_UPDATE", FileKey: "file" + strings.Repeat("x", i), FileName: "Design system"},
+		})
+	}
+	rl.deliverDigest(context.Background(), ep, jobs)
+
+	if len(notifier.calls) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(notifier.calls))
+	}
+	got := notifier.calls[0]
+	if want := "[Figma] Figma Digest: 20 events (design)"; got.title != want {
+		t.Errorf("title = %q, want %q", got.title, want)
+	}
+	footer := "\n\n---\nSource: relay\nEvent: DIGEST"
+	if !strings.HasSuffix(got.description, footer) {
+		t.Errorf("description doesn't end with the source footer: %q", got.description)
+	}
+	if n := utf8.RuneCountInString(got.description); n > cfg.MaxDescriptionChars {
+		t.Errorf("description is %d characters, want at most %d", n, cfg.MaxDescriptionChars)
+	}
+	if !strings.Contains(got.description, truncatedSuffix+footer) {
+		t.Errorf("description wasn't truncated before the footer: %q", got.description)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"strings"
+)
+
+const defaultEndpointPath = "/create-issue"
+
+// endpoint is one inbound webhook path. Each has its own notifiers, built
+// from the global config with that endpoint's Linear credentials and team
+// swapped in; everything else is shared.
+type endpoint struct {
+	name      string
+	path      string
+	cfg       *Config
+	notifiers []Notifier
+}
+
+func newEndpoints(cfg *Config, client *http.Client, audit *auditLog) ([]*endpoint, error) {
+	shared := linearShared{audit: audit}
+	if cfg.LinearBreakerThreshold > 0 {
+		shared.breaker = newCircuitBreaker(cfg.LinearBreakerThreshold, cfg.LinearBreakerCooldown)
+	}
+	if cfg.LinearTeamRatePerMinute > 0 {
+		shared.teamLimits = newTeamRateLimiter(cfg.LinearTeamRatePerMinute, cfg.LinearTeamRateBurst)
+	}
+
+	notifiers, err := newNotifiers(cfg, client, shared)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := []*endpoint{{path: defaultEndpointPath, cfg: cfg, notifiers: notifiers}}
+
+	for _, ec := range cfg.Endpoints {
+		epCfg := cfg.forEndpoint(ec)
+		notifiers, err := newNotifiers(epCfg, client, shared)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, &endpoint{
+			name:      ec.Name,
+			path:      defaultEndpointPath + "/" + ec.Name,
+			cfg:       epCfg,
+			notifiers: notifiers,
+		})
+	}
+	return endpoints, nil
+}
+
+// forEndpoint returns a copy of cfg with the endpoint's overrides applied.
+// Unset overrides fall back to the global values.
+func (cfg *Config) forEndpoint(ec EndpointConfig) *Config {
+	c := *cfg
+	c.LinearAPIKey = cmp.Or(ec.LinearAPIKey, cfg.LinearAPIKey)
+	c.LinearTeamID = cmp.Or(ec.LinearTeamID, cfg.LinearTeamID)
+	c.FigmaWebhookPasscode = cmp.Or(ec.FigmaWebhookPasscode, cfg.FigmaWebhookPasscode)
+	c.endpointName = ec.Name
+	return &c
+}
+
+// dedupKey namespaces the delivery key by endpoint so the same file relayed
+// for two orgs is tracked separately. The default endpoint keeps the bare
+// key so existing dedup entries stay valid.
+func (ep *endpoint) dedupKey(webhook FigmaWebhook) string {
+	return ep.scopedKey(dedupKey(webhook))
+}
+
+// linear returns the endpoint's Linear notifier, or nil when it files
+// issues elsewhere.
+func (ep *endpoint) linear() *LinearNotifier {
+	for _, n := range ep.notifiers {
+		if linear, ok := n.(*LinearNotifier); ok {
+			return linear
+		}
+	}
+	return nil
+}
+
+func (ep *endpoint) scopedKey(key string) string {
+	if ep.name == "" {
+		return key
+	}
+	return ep.name + "|" + key
+}
+
+func validEndpointName(name string) bool {
+	return name != "" && strings.Trim(name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") == ""
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const defaultDotenvPath = ".env"
+
+// loadDotenv loads DOTENV_PATH (default .env) into the environment without
+// overriding variables that are already set. A missing default file is
+// fine; a missing DOTENV_PATH is an error. DISABLE_DOTENV=true skips
+// loading entirely, which is what production deploys should use.
+func loadDotenv() error {
+	if disabled, _ := strconv.ParseBool(os.Getenv("DISABLE_DOTENV")); disabled {
+		slog.Debug("Skipping env file, DISABLE_DOTENV is set")
+		return nil
+	}
+
+	path := os.Getenv("DOTENV_PATH")
+	explicit := path != ""
+	if !explicit {
+		path = defaultDotenvPath
+	}
+
+	err := godotenv.Load(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		slog.Debug("No env file found", "path", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load env file %s: %w", path, err)
+	}
+	slog.Debug("Loaded env file", "path", path)
+	return nil
+}
+
+// envReader reads typed values from the environment and collects every
+// problem it finds, so a misconfigured deploy reports all of them at once.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) fail(err error) {
+	if err != nil {
+		e.errs = append(e.errs, err)
+	}
+}
+
+func (e *envReader) err() error {
+	return errors.Join(e.errs...)
+}
+
+func (e *envReader) str(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func (e *envReader) integer(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.fail(fmt.Errorf("%s must be an integer, got %q", key, v))
+		return def
+	}
+
+	return n
+}
+
+func (e *envReader) duration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.fail(fmt.Errorf("%s must be a duration like 10s, got %q", key, v))
+		return def
+	}
+
+	return d
+}
+
+func (e *envReader) boolean(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.fail(fmt.Errorf("%s must be a boolean, got %q", key, v))
+		return def
+	}
+
+	return b
+}
+
+func (e *envReader) list(key string, def []string) []string {
+	if os.Getenv(key) == "" {
+		return def
+	}
+	return splitList(os.Getenv(key))
+}
+
+// splitList splits a comma-separated value, dropping blank entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (e *envReader) jsonMap(key string, def map[string]string) map[string]string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	var m map[string]string
+	if err := json.Unmarshal([]byte(v), &m); err != nil {
+		e.fail(fmt.Errorf("%s must be a JSON object of strings: %w", key, err))
+		return def
+	}
+
+	return m
+}
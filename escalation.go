@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// publishCounter counts LIBRARY_PUBLISH events per file over a rolling
+// window, so a file that keeps publishing can be flagged as churning.
+type publishCounter struct {
+	mu     sync.Mutex
+	window time.Duration
+	hits   map[string][]time.Time
+}
+
+func newPublishCounter(window time.Duration) *publishCounter {
+	c := &publishCounter{window: window, hits: make(map[string][]time.Time)}
+	go runCleanup(window, c.cleanup)
+	return c
+}
+
+// record adds a publish for fileKey and returns how many fall within the
+// window, including this one.
+func (c *publishCounter) record(fileKey string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	hits := append(c.recent(c.hits[fileKey], now), now)
+	c.hits[fileKey] = hits
+	return len(hits)
+}
+
+func (c *publishCounter) recent(hits []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-c.window)
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
+
+func (c *publishCounter) cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, hits := range c.hits {
+		if hits = c.recent(hits, now); len(hits) == 0 {
+			delete(c.hits, key)
+		} else {
+			c.hits[key] = hits
+		}
+	}
+}
+
+// escalatePriority returns the more urgent of two Linear priorities. Linear
+// uses 1 for urgent through 4 for low, with 0 meaning no priority.
+func escalatePriority(current, target int) int {
+	if current == 0 || target < current {
+		return target
+	}
+	return current
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type issueBuilder func(webhook FigmaWebhook) (title, description string)
+
+var eventHandlers = map[string]issueBuilder{
+	"LIBRARY_PUBLISH":        libraryPublishIssue,
+	"FILE_UPDATE":            fileUpdateIssue,
+	"FILE_COMMENT":           fileCommentIssue,
+	"FILE_DELETE":            fileDeleteIssue,
+	"FILE_VERSION_UPDATE":    fileVersionUpdateIssue,
+	"DEV_MODE_STATUS_UPDATE": devModeStatusIssue,
+	"PING":                   pingIssue,
+}
+
+// optInEvents are disabled unless explicitly enabled, since most teams
+// don't use them.
+var optInEvents = map[string]bool{
+	"DEV_MODE_STATUS_UPDATE": true,
+	"PING":                   true,
+}
+
+func (w FigmaWebhook) fileLabel() string {
+	if w.FileName != "" {
+		return w.FileName
+	}
+	return w.FileKey
+}
+
+func (c FileCommentEvent) text() string {
+	var sb strings.Builder
+	for _, f := range c.Comment {
+		if f.Mention != "" {
+			sb.WriteString("@" + c.mentionHandle(f.Mention))
+			continue
+		}
+		sb.WriteString(f.Text)
+	}
+	return sb.String()
+}
+
+// textMention matches an @handle typed into comment text, as opposed to a
+// structured mention fragment. It must start the text or follow
+// whitespace, so email addresses don't match.
+var textMention = regexp.MustCompile(`(?:^|\s)@([\w.-]+)`)
+
+// mentionedUsers lists the users the comment @-mentions, in the order they
+// appear. Structured mentions are resolved against the payload's mentions
+// list; plain-text @handles only carry the handle.
+func (c FileCommentEvent) mentionedUsers() []User {
+	var users []User
+	for _, f := range c.Comment {
+		if f.Mention != "" {
+			users = append(users, c.mentionedUser(f.Mention))
+			continue
+		}
+		for _, m := range textMention.FindAllStringSubmatch(f.Text, -1) {
+			users = append(users, User{Handle: strings.TrimRight(m[1], ".")})
+		}
+	}
+	return users
+}
+
+func (c FileCommentEvent) mentionedUser(id string) User {
+	for _, u := range c.Mentions {
+		if u.ID == id {
+			return u
+		}
+	}
+	return User{ID: id}
+}
+
+// mentionHandle resolves a mentioned user ID to its handle using the
+// payload's mentions list, falling back to the raw ID.
+func (c FileCommentEvent) mentionHandle(id string) string {
+	for _, u := range c.Mentions {
+		if u.ID == id && u.Handle != "" {
+			return u.Handle
+		}
+	}
+	return id
+}
+
+// sourceFooter marks an issue as relay-created so it can be found by
+// searching the description, e.g. for "Source: relay". Events without a
+// single file, such as digests, leave out the File line.
+func sourceFooter(webhook FigmaWebhook) string {
+	if webhook.FileKey == "" {
+		return fmt.Sprintf("\n\n---\nSource: relay\nEvent: %s", webhook.EventType)
+	}
+	return fmt.Sprintf("\n\n---\nSource: relay\nFile: %s\nEvent: %s", webhook.FileKey, webhook.EventType)
+}
+
+const truncatedSuffix = "…(truncated)"
+
+// truncateRunes shortens s to at most max characters, counting runes so a
+// multibyte character is never split, and marks the cut with a suffix.
+func truncateRunes(s string, max int) (string, bool) {
+	if utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+	keep := max - utf8.RuneCountInString(truncatedSuffix)
+	for i := range s {
+		if keep == 0 {
+			return s[:i] + truncatedSuffix, true
+		}
+		keep--
+	}
+	return s, false
+}
+
+const maxListedComponents = 50
+
+type componentChange struct {
+	Component
+	Change string
+}
+
+func (e LibraryPublishEvent) changes() []componentChange {
+	var changes []componentChange
+	for _, c := range e.CreatedComponents {
+		changes = append(changes, componentChange{c, "added"})
+	}
+	for _, c := range e.ModifiedComponents {
+		changes = append(changes, componentChange{c, "modified"})
+	}
+	for _, c := range e.DeletedComponents {
+		changes = append(changes, componentChange{c, "deleted"})
+	}
+	return changes
+}
+
+func componentList(changes []componentChange) string {
+	var sb strings.Builder
+	for i, c := range changes {
+		if i == maxListedComponents {
+			fmt.Fprintf(&sb, "- and %d more\n", len(changes)-maxListedComponents)
+			break
+		}
+
+		fmt.Fprintf(&sb, "- **%s** (%s)", escapeMarkdown(c.Name), c.Change)
+		if c.Desc != "" {
+			fmt.Fprintf(&sb, ": %s", escapeMarkdown(c.Desc))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func libraryPublishIssue(webhook FigmaWebhook) (string, string) {
+	title := fmt.Sprintf("Figma Library Published: %s", webhook.fileLabel())
+	description := fmt.Sprintf("The Figma file with key %s has published a new library at %s.", webhook.FileKey, webhook.Timestamp)
+	if webhook.Description != "" {
+		description += "\n\n" + quoteMarkdown(webhook.Description)
+	}
+	if changes := webhook.changes(); len(changes) > 0 {
+		description += fmt.Sprintf("\n\n### Components (%d)\n\n", len(changes)) + componentList(changes)
+	}
+	return title, description
+}
+
+func fileUpdateIssue(webhook FigmaWebhook) (string, string) {
+	title := fmt.Sprintf("Figma File Updated: %s", webhook.fileLabel())
+	description := fmt.Sprintf("The Figma file with key %s was updated at %s.", webhook.FileKey, webhook.Timestamp)
+	return title, description
+}
+
+func fileCommentIssue(webhook FigmaWebhook) (string, string) {
+	title := fmt.Sprintf("New Figma Comment: %s", webhook.fileLabel())
+	description := fmt.Sprintf("%s commented on the Figma file with key %s at %s:\n\n%s",
+		escapeMarkdown(webhook.TriggeredBy.String()), webhook.FileKey, webhook.Timestamp, quoteMarkdown(webhook.FileCommentEvent.text()))
+	if webhook.CommentID != "" {
+		description += fmt.Sprintf("\n\n[View comment thread](%s)", figmaCommentURL(webhook.FileKey, webhook.CommentID))
+	}
+	return title, description
+}
+
+func fileDeleteIssue(webhook FigmaWebhook) (string, string) {
+	title := fmt.Sprintf("Figma File Deleted: %s", webhook.fileLabel())
+	description := fmt.Sprintf("The Figma file with key %s was deleted at %s.", webhook.FileKey, webhook.Timestamp)
+	return title, description
+}
+
+func fileVersionUpdateIssue(webhook FigmaWebhook) (string, string) {
+	title := fmt.Sprintf("Figma Version Created: %s", webhook.fileLabel())
+	if webhook.Label != "" {
+		title = fmt.Sprintf("Figma Version %q: %s", webhook.Label, webhook.fileLabel())
+	}
+	description := fmt.Sprintf("%s saved a new version of the Figma file with key %s at %s.", escapeMarkdown(webhook.TriggeredBy.String()), webhook.FileKey, webhook.Timestamp)
+	if webhook.TriggeredBy == (User{}) {
+		description = fmt.Sprintf("A new version of the Figma file with key %s was saved at %s.", webhook.FileKey, webhook.Timestamp)
+	}
+	if webhook.Description != "" {
+		description += "\n\n" + quoteMarkdown(webhook.Description)
+	}
+	if webhook.VersionID != "" {
+		description += fmt.Sprintf("\n\n[View version in file history](%s)", figmaVersionURL(webhook.FileKey, webhook.VersionID))
+	}
+	return title, description
+}
+
+func devModeStatusIssue(webhook FigmaWebhook) (string, string) {
+	status := devModeStatusLabel(webhook.Status)
+	title := fmt.Sprintf("Figma Dev Status %s: %s", status, webhook.fileLabel())
+	if webhook.Status == "READY_FOR_DEV" {
+		title = fmt.Sprintf("Figma Ready for Dev: %s", webhook.fileLabel())
+	}
+	description := fmt.Sprintf("%s moved node %s in the Figma file with key %s to \"%s\" at %s.",
+		escapeMarkdown(webhook.TriggeredBy.String()), webhook.NodeID, webhook.FileKey, escapeMarkdown(status), webhook.Timestamp)
+	if webhook.ChangeMessage != "" {
+		description += "\n\n" + quoteMarkdown(webhook.ChangeMessage)
+	}
+	if webhook.NodeID != "" {
+		description += fmt.Sprintf("\n\n[View node](%s)", figmaNodeURL(webhook.FileKey, webhook.NodeID))
+	}
+	return title, description
+}
+
+// devModeStatusLabel turns a status like READY_FOR_DEV into the wording
+// Figma shows in the UI.
+func devModeStatusLabel(status string) string {
+	switch status {
+	case "READY_FOR_DEV":
+		return "Ready for dev"
+	case "COMPLETED":
+		return "Completed"
+	case "NONE", "":
+		return "No status"
+	}
+	return status
+}
+
+// pingIssue confirms a newly registered webhook end to end. Figma sends
+// PING once when the subscription is created, so this files at most one
+// issue per registration.
+func pingIssue(webhook FigmaWebhook) (string, string) {
+	title := fmt.Sprintf("Figma Webhook Verified: %s", webhook.WebhookID)
+	description := fmt.Sprintf("Figma sent a PING for webhook %s at %s, confirming that its deliveries reach relay and pass signature verification.",
+		webhook.WebhookID, webhook.Timestamp)
+	return title, description
+}
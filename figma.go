@@ -0,0 +1,196 @@
+package main
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+type User struct {
+	ID     string `json:"id"`
+	Handle string `json:"handle"`
+}
+
+func (u User) String() string {
+	if u.Handle != "" {
+		return u.Handle
+	}
+	return u.ID
+}
+
+// UnmarshalJSON also accepts a bare string, which older payloads used for
+// triggered_by, and treats it as the user's handle.
+func (u *User) UnmarshalJSON(data []byte) error {
+	var handle string
+	if err := json.Unmarshal(data, &handle); err == nil {
+		*u = User{Handle: handle}
+		return nil
+	}
+
+	type user User
+	return json.Unmarshal(data, (*user)(u))
+}
+
+type Component struct {
+	Key       string `json:"key"`
+	Name      string `json:"name"`
+	Desc      string `json:"description"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type Library struct {
+	ID                  string      `json:"id"`
+	Name                string      `json:"name"`
+	FileKey             string      `json:"file_key"`
+	PublishedComponents []Component `json:"published_components"`
+}
+
+type CommentFragment struct {
+	Text    string `json:"text,omitempty"`
+	Mention string `json:"mention,omitempty"`
+}
+
+type FileCommentEvent struct {
+	CommentID string            `json:"comment_id"`
+	Comment   []CommentFragment `json:"comment"`
+	Mentions  []User            `json:"mentions"`
+}
+
+type LibraryPublishEvent struct {
+	CreatedComponents  []Component `json:"created_components"`
+	ModifiedComponents []Component `json:"modified_components"`
+	DeletedComponents  []Component `json:"deleted_components"`
+}
+
+type FileVersionUpdateEvent struct {
+	VersionID string `json:"version_id"`
+	Label     string `json:"label"`
+}
+
+// DevModeStatusUpdateEvent is sent when a node's Dev Mode status changes,
+// e.g. to READY_FOR_DEV.
+type DevModeStatusUpdateEvent struct {
+	NodeID        string `json:"node_id"`
+	Status        string `json:"status"`
+	ChangeMessage string `json:"change_message"`
+}
+
+type FigmaWebhook struct {
+	EventType   string `json:"event_type"`
+	FileKey     string `json:"file_key"`
+	FileName    string `json:"file_name"`
+	Timestamp   string `json:"timestamp"`
+	CreatedAt   string `json:"created_at"`
+	TriggeredBy User   `json:"triggered_by"`
+	Description string `json:"description"`
+	LibraryPublishEvent
+	FileCommentEvent
+	FileVersionUpdateEvent
+	DevModeStatusUpdateEvent
+	// WebhookID identifies the subscription that sent the event.
+	WebhookID string `json:"webhook_id"`
+	Webhooks  []struct {
+		ID       string `json:"id"`
+		TeamID   string `json:"team_id"`
+		Endpoint string `json:"endpoint"`
+	} `json:"webhooks"`
+}
+
+const figmaSignatureHeader = "X-Figma-Signature"
+
+// knownWebhookFields are the top-level payload keys FigmaWebhook decodes,
+// plus passcode, which signature verification handles.
+var knownWebhookFields = jsonFieldNames(reflect.TypeFor[FigmaWebhook](), map[string]bool{"passcode": true})
+
+// jsonFieldNames adds the JSON keys of t's fields to names, descending into
+// embedded structs the way encoding/json flattens them.
+func jsonFieldNames(t reflect.Type, names map[string]bool) map[string]bool {
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || (f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		names[cmp.Or(name, f.Name)] = true
+	}
+	return names
+}
+
+// unknownWebhookFields lists the top-level keys in a payload that
+// FigmaWebhook doesn't decode, sorted, so new fields Figma adds get noticed.
+func unknownWebhookFields(body []byte) []string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil
+	}
+	var unknown []string
+	for key := range raw {
+		if !knownWebhookFields[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+// figmaTeamID returns the Figma team that owns the webhook subscription, if
+// the payload carries subscription metadata.
+func (w FigmaWebhook) figmaTeamID() string {
+	for _, wh := range w.Webhooks {
+		if wh.TeamID != "" {
+			return wh.TeamID
+		}
+	}
+	return ""
+}
+
+// maxClockSkew is how far in the future a webhook timestamp may be before
+// it is treated as invalid.
+const maxClockSkew = time.Minute
+
+// eventAge returns how long ago the webhook was sent according to its
+// timestamp.
+func (w FigmaWebhook) eventAge(now time.Time) (time.Duration, error) {
+	ts, err := time.Parse(time.RFC3339, w.Timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", w.Timestamp)
+	}
+	return now.Sub(ts), nil
+}
+
+func verifyFigmaSignature(body []byte, header string, secret string) bool {
+	if header == "" || secret == "" {
+		return false
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// matchFigmaSignature checks the signature against each accepted passcode,
+// which lets an old and new passcode overlap during rotation. It returns
+// the index of the passcode that matched.
+func matchFigmaSignature(body []byte, header string, secrets []string) (int, bool) {
+	for i, secret := range secrets {
+		if verifyFigmaSignature(body, header, secret) {
+			return i, true
+		}
+	}
+	return -1, false
+}
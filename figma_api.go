@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const figmaAPIURL = "https://api.figma.com"
+
+type figmaWebhookCreate struct {
+	EventType   string `json:"event_type"`
+	TeamID      string `json:"team_id"`
+	Endpoint    string `json:"endpoint"`
+	Passcode    string `json:"passcode"`
+	Description string `json:"description,omitempty"`
+}
+
+type figmaWebhookSubscription struct {
+	ID        string `json:"id"`
+	TeamID    string `json:"team_id"`
+	EventType string `json:"event_type"`
+	Endpoint  string `json:"endpoint"`
+	Passcode  string `json:"passcode"`
+	Status    string `json:"status"`
+}
+
+func figmaRequest(ctx context.Context, cfg *Config, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, figmaAPIURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Figma-Token", cfg.FigmaAPIToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("figma %s %s returned %s: %s", method, path, resp.Status, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode Figma response: %w", err)
+	}
+	return nil
+}
+
+func createFigmaWebhook(ctx context.Context, cfg *Config, create figmaWebhookCreate) (figmaWebhookSubscription, error) {
+	var sub figmaWebhookSubscription
+	err := figmaRequest(ctx, cfg, "POST", "/v2/webhooks", create, &sub)
+	return sub, err
+}
+
+type figmaFileMeta struct {
+	Name         string `json:"name"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+type cachedFileMeta struct {
+	meta      figmaFileMeta
+	expiresAt time.Time
+}
+
+// figmaFileCache briefly remembers file metadata so bursts of events for the
+// same file don't each cost a Figma API call.
+type figmaFileCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedFileMeta
+}
+
+func newFigmaFileCache(ttl time.Duration) *figmaFileCache {
+	return &figmaFileCache{ttl: ttl, entries: make(map[string]cachedFileMeta)}
+}
+
+func (c *figmaFileCache) get(fileKey string) (figmaFileMeta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[fileKey]
+	if !ok {
+		return figmaFileMeta{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, fileKey)
+		return figmaFileMeta{}, false
+	}
+	return entry.meta, true
+}
+
+func (c *figmaFileCache) put(fileKey string, meta figmaFileMeta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[fileKey] = cachedFileMeta{meta: meta, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (rl *relay) fetchFigmaFileMeta(ctx context.Context, fileKey string) (figmaFileMeta, error) {
+	if meta, ok := rl.figmaFiles.get(fileKey); ok {
+		return meta, nil
+	}
+
+	var meta figmaFileMeta
+	if err := figmaRequest(ctx, rl.cfg, "GET", "/v1/files/"+url.PathEscape(fileKey)+"?depth=1", nil, &meta); err != nil {
+		return figmaFileMeta{}, err
+	}
+	rl.figmaFiles.put(fileKey, meta)
+	return meta, nil
+}
+
+func figmaFileURL(fileKey string) string {
+	return "https://figma.com/file/" + fileKey
+}
+
+// figmaNodeURL links to a node. URLs spell node IDs with a dash where the
+// API uses a colon.
+func figmaNodeURL(fileKey, nodeID string) string {
+	return "https://www.figma.com/file/" + fileKey + "?node-id=" + url.QueryEscape(strings.ReplaceAll(nodeID, ":", "-"))
+}
+
+// figmaVersionURL opens the file at a saved version, with the version
+// history panel showing it.
+func figmaVersionURL(fileKey, versionID string) string {
+	return "https://www.figma.com/file/" + fileKey + "?version-id=" + url.QueryEscape(versionID)
+}
+
+func figmaCommentURL(fileKey, commentID string) string {
+	return "https://www.figma.com/file/" + fileKey + "#" + commentID
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"flag"
+)
+
+// applyFlags overrides config values with any command-line flags. Each
+// flag defaults to the value already resolved from the file and env, so
+// only flags that are passed change anything.
+func applyFlags(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("relay", flag.ContinueOnError)
+	fs.StringVar(&cfg.Port, "port", cfg.Port, "HTTP listen port (PORT)")
+	fs.StringVar(&cfg.LinearAPIURL, "linear-api-url", cfg.LinearAPIURL, "Linear GraphQL endpoint (LINEAR_API_URL)")
+	fs.StringVar(&cfg.LinearTeamID, "linear-team-id", cfg.LinearTeamID, "default Linear team ID (LINEAR_TEAM_ID)")
+	fs.BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "log issues instead of creating them (DRY_RUN)")
+	fs.IntVar(&cfg.WorkerCount, "workers", cfg.WorkerCount, "number of background workers (WORKER_COUNT)")
+	fs.BoolVar(&cfg.validateOnly, "validate", false, "check config and credentials, then exit")
+	return fs.Parse(args)
+}
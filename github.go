@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const githubAPIURL = "https://api.github.com"
+
+type GitHubNotifier struct {
+	cfg    *Config
+	client *http.Client
+}
+
+type githubIssueRequest struct {
+	Title  string   `json:"title"`
+	Body   string   `json:"body"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+type githubIssueResponse struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+func (n *GitHubNotifier) labels(event FigmaWebhook) []string {
+	labels := append([]string{}, n.cfg.GitHubLabels...)
+	return append(labels, "figma:"+strings.ToLower(strings.ReplaceAll(event.EventType, "_", "-")))
+}
+
+func (n *GitHubNotifier) CreateIssue(ctx context.Context, event FigmaWebhook, title, description string) (string, error) {
+	b, err := json.Marshal(githubIssueRequest{
+		Title:  title,
+		Body:   description,
+		Labels: n.labels(event),
+	})
+	if err != nil {
+		return "", fmt.Errorf("github: %w", err)
+	}
+
+	if n.cfg.DryRun {
+		loggerFrom(ctx).Info("DRY_RUN: skipping GitHub API call", "repo", n.cfg.GitHubRepo, "title", title)
+		loggerFrom(ctx).Debug("DRY_RUN: GitHub request payload", "payload", string(b))
+		return "dry-run", nil
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/issues", githubAPIURL, n.cfg.GitHubRepo)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
+	if err != nil {
+		return "", fmt.Errorf("github: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+n.cfg.GitHubToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("github: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("github: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("github: create issue returned %s: %s", resp.Status, string(body))
+	}
+
+	var issue githubIssueResponse
+	if err := json.Unmarshal(body, &issue); err != nil {
+		return "", fmt.Errorf("github: failed to decode response: %w", err)
+	}
+
+	issueID := fmt.Sprintf("%s#%d", n.cfg.GitHubRepo, issue.Number)
+	loggerFrom(ctx).Info("Created GitHub issue", "issue_id", issueID, "url", issue.HTMLURL)
+	return issueID, nil
+}
+
+func (n *GitHubNotifier) Notify(ctx context.Context, event FigmaWebhook, title, description string) error {
+	_, err := n.CreateIssue(ctx, event, title, description)
+	return err
+}
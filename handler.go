@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type relay struct {
+	cfg         *Config
+	dedup       DedupStore
+	pending     *pendingKeys
+	queue       *workQueue
+	endpoints   []*endpoint
+	limiter     *fileRateLimiter
+	batcher     *publishBatcher
+	debouncer   *fileDebouncer
+	digest      *eventDigest
+	figmaFiles  *figmaFileCache
+	history     *eventHistory
+	stream      *eventBroadcaster
+	audit       *auditLog
+	deadLetters *auditLog
+}
+
+func newRelay(cfg *Config) (*relay, error) {
+	dedup, err := newDedupStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	audit, err := newAuditLog(cfg.AuditLogPath)
+	if err != nil {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+	deadLetters, err := newAuditLog(cfg.DeadLetterPath)
+	if err != nil {
+		return nil, fmt.Errorf("dead-letter file: %w", err)
+	}
+
+	endpoints, err := newEndpoints(cfg, httpClient, audit)
+	if err != nil {
+		return nil, err
+	}
+
+	rl := &relay{
+		cfg:         cfg,
+		dedup:       dedup,
+		pending:     newPendingKeys(),
+		queue:       newWorkQueue(cfg.QueueSize, cfg.QueueFullPolicy == "block"),
+		endpoints:   endpoints,
+		figmaFiles:  newFigmaFileCache(cfg.FigmaFileCacheTTL),
+		history:     newEventHistory(cfg.EventHistorySize),
+		stream:      newEventBroadcaster(),
+		audit:       audit,
+		deadLetters: deadLetters,
+	}
+	if cfg.RateLimitPerMinute > 0 {
+		rl.limiter = newFileRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+	}
+	if cfg.BatchWindow > 0 {
+		rl.batcher = newPublishBatcher(cfg.BatchWindow, rl.flushBatch)
+	}
+	if cfg.DebounceInterval > 0 {
+		rl.debouncer = newFileDebouncer(cfg.DebounceInterval, rl.flushDebounced)
+	}
+	if cfg.DigestMode {
+		rl.digest = newEventDigest(cfg.DigestInterval, rl.deliverDigest)
+	}
+	rl.queue.Start(cfg.WorkerCount, func(ctx context.Context, job issueJob) {
+		rl.processIssueJob(ctx, job)
+	}, rl.dropJob)
+	return rl, nil
+}
+
+func (rl *relay) Close(ctx context.Context) {
+	if rl.batcher != nil {
+		rl.batcher.Close()
+	}
+	if rl.debouncer != nil {
+		rl.debouncer.Close()
+	}
+	if rl.digest != nil {
+		rl.digest.Close(ctx)
+	}
+
+	if err := rl.queue.Shutdown(ctx); err != nil {
+		slog.Error("Work queue did not drain cleanly", "error", err)
+	} else {
+		slog.Info("Work queue drained")
+	}
+
+	if closer, ok := rl.dedup.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Error("Failed to close dedup store", "error", err)
+		}
+	}
+
+	if err := rl.audit.Close(); err != nil {
+		slog.Error("Failed to close audit log", "error", err)
+	}
+	if err := rl.deadLetters.Close(); err != nil {
+		slog.Error("Failed to close dead-letter file", "error", err)
+	}
+}
+
+// payloadJSON returns the JSON payload of a webhook request. Form-encoded
+// bodies carry it in FORM_PAYLOAD_FIELD; anything else is taken as raw
+// JSON. A form content type on a body that is already a JSON object, as
+// curl -d sends by default, is also taken as raw JSON.
+func (rl *relay) payloadJSON(r *http.Request, body []byte) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" || bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return body, nil
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid form body: %w", err)
+	}
+	payload := form.Get(rl.cfg.FormPayloadField)
+	if payload == "" {
+		return nil, fmt.Errorf("form body has no %q field", rl.cfg.FormPayloadField)
+	}
+	return []byte(payload), nil
+}
+
+func (rl *relay) createIssueHandler(ep *endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "figma.webhook", trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("relay.endpoint", ep.path)))
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		logger := loggerFrom(r.Context())
+		if ep.name != "" {
+			logger = logger.With("endpoint", ep.name)
+		}
+
+		idemKey, err := idempotencyKey(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeReadFailed, "Failed to read request body")
+			return
+		}
+
+		body, err = rl.payloadJSON(r, body)
+		if err != nil {
+			logger.Warn("Rejected form-encoded webhook", "error", err)
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+
+		var webhook FigmaWebhook
+		if err := json.Unmarshal(body, &webhook); err != nil {
+			logger.Warn("Rejected malformed webhook JSON", "error", err)
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON")
+			return
+		}
+
+		logger = logger.With("event_type", webhook.EventType, "file_key", webhook.FileKey)
+		// Decoding twice only pays off when the result will be logged.
+		if logger.Enabled(r.Context(), slog.LevelDebug) {
+			if unknown := unknownWebhookFields(body); len(unknown) > 0 {
+				logger.Debug("Webhook payload has unrecognized fields", "fields", unknown)
+			}
+		}
+		span.SetAttributes(
+			attribute.String("figma.event_type", webhook.EventType),
+			attribute.String("figma.file_key", webhook.FileKey),
+		)
+		if len(webhook.Webhooks) > 0 {
+			wh := webhook.Webhooks[0]
+			logger = logger.With("webhook_id", wh.ID, "figma_team_id", wh.TeamID)
+			logger.Debug("Webhook subscription metadata", "webhook_endpoint", wh.Endpoint, "subscriptions", len(webhook.Webhooks))
+		}
+		logger.Info("Received Figma webhook", "timestamp", webhook.Timestamp)
+		webhooksReceived.WithLabelValues(webhook.EventType).Inc()
+		stats.webhookReceived(webhook.EventType)
+
+		if rl.cfg.MaxEventAge > 0 {
+			age, err := webhook.eventAge(time.Now())
+			if err != nil {
+				logger.Warn("Rejected webhook with unparseable timestamp", "error", err)
+				writeJSONError(w, http.StatusBadRequest, errCodeInvalidTimestamp, err.Error())
+				return
+			}
+			logger.Debug("Measured webhook age", "age", age.String())
+			if age > rl.cfg.MaxEventAge || age < -maxClockSkew {
+				logger.Warn("Rejected stale webhook", "age", age.String(), "max_age", rl.cfg.MaxEventAge.String())
+				webhooksDropped.WithLabelValues("stale").Inc()
+				writeJSONError(w, http.StatusBadRequest, errCodeStaleEvent, fmt.Sprintf("Event timestamp is outside the accepted window (age %s)", age.Round(time.Second)))
+				return
+			}
+		}
+
+		if _, ok := eventHandlers[webhook.EventType]; !ok {
+			logger.Info("Event type not handled")
+			rl.recordEvent(webhook, "unhandled", "", nil)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Event type not handled"))
+			return
+		}
+
+		// A PING has already passed signature verification, which is all it
+		// is meant to prove. It only becomes an issue when PING is enabled.
+		if webhook.EventType == "PING" {
+			logger.Info("Figma webhook subscription verified", "webhook_id", webhook.WebhookID)
+			if !rl.cfg.eventEnabled(webhook.EventType) {
+				rl.recordEvent(webhook, "ping", "", nil)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("Ping received, webhook subscription is healthy"))
+				return
+			}
+		}
+
+		if !rl.cfg.eventEnabled(webhook.EventType) {
+			logger.Info("Event type disabled, ignoring webhook")
+			webhooksDropped.WithLabelValues("event_disabled").Inc()
+			rl.recordEvent(webhook, "disabled", "", nil)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Event disabled"))
+			return
+		}
+
+		if webhook.EventType != "PING" && !rl.cfg.fileAllowed(webhook.FileKey) {
+			logger.Info("File not in allowlist, ignoring webhook")
+			rl.recordEvent(webhook, "not_allowed", "", nil)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("File not in allowlist"))
+			return
+		}
+
+		if webhook.EventType == "FILE_COMMENT" && !rl.cfg.commentTriggered(webhook.FileCommentEvent.text()) {
+			logger.Info("Comment does not match trigger, ignoring webhook")
+			rl.recordEvent(webhook, "not_triggered", "", nil)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Comment does not match trigger"))
+			return
+		}
+
+		key := ep.dedupKey(webhook)
+		if idemKey != "" {
+			key = ep.scopedKey(idemKey)
+		}
+		// Reserve the key before checking the store: a job records its keys
+		// before releasing them, so one of the two always catches a repeat.
+		if !rl.pending.reserve(key) {
+			logger.Info("Duplicate webhook delivery ignored, first delivery still in progress")
+			rl.recordEvent(webhook, "duplicate", "", nil)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Duplicate delivery ignored, first delivery still in progress"))
+			return
+		}
+		if issueID, ok := rl.dedup.Seen(key); ok {
+			rl.pending.release(key)
+			logger.Info("Duplicate webhook delivery ignored", "issue_id", issueID)
+			rl.recordEvent(webhook, "duplicate", issueID, nil)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Duplicate delivery ignored, issue " + issueID))
+			return
+		}
+
+		if rl.limiter != nil && !rl.limiter.Allow(webhook.FileKey) {
+			rl.pending.release(key)
+			logger.Warn("Rate limit exceeded for file, dropping webhook")
+			webhooksDropped.WithLabelValues("rate_limited").Inc()
+			rl.recordEvent(webhook, "rate_limited", "", nil)
+			writeJSONError(w, http.StatusTooManyRequests, errCodeRateLimited, "Rate limit exceeded for this file")
+			return
+		}
+
+		job := issueJob{
+			logger:    logger,
+			endpoint:  ep,
+			dedupKeys: []string{key},
+			event:     webhook,
+			trace:     span.SpanContext(),
+		}
+
+		if rl.digest != nil && rl.digest.Add(job) {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Webhook accepted for digest"))
+			return
+		}
+
+		if rl.batcher != nil && webhook.EventType == "LIBRARY_PUBLISH" && rl.batcher.Add(job) {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Webhook accepted for batching"))
+			return
+		}
+
+		if rl.debouncer != nil && webhook.EventType == "FILE_UPDATE" && rl.debouncer.Add(job) {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Webhook accepted for debouncing"))
+			return
+		}
+
+		if !rl.queue.Enqueue(r.Context(), job) {
+			rl.pending.release(key)
+			logger.Error("Work queue is full, dropping webhook")
+			rl.recordEvent(webhook, "queue_full", "", nil)
+			writeJSONError(w, http.StatusServiceUnavailable, errCodeQueueFull, "Server is busy, try again later")
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Webhook accepted"))
+	}
+}
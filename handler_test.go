@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatedNotifier holds each CreateIssue call until the test lets it finish.
+type gatedNotifier struct {
+	proceed chan error
+}
+
+func (n *gatedNotifier) Notify(ctx context.Context, event FigmaWebhook, title, description string) error {
+	_, err := n.CreateIssue(ctx, event, title, description)
+	return err
+}
+
+func (n *gatedNotifier) CreateIssue(ctx context.Context, _ FigmaWebhook, _, _ string) (string, error) {
+	select {
+	case err := <-n.proceed:
+		if err != nil {
+			return "", err
+		}
+		return "issue-1", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// newQueueTestRelay builds a relay whose single worker delivers through
+// notifiers, without the batching stages newRelay may add.
+func newQueueTestRelay(t *testing.T, cfg *Config, notifiers ...Notifier) (*relay, *endpoint) {
+	t.Helper()
+	ep := &endpoint{cfg: cfg, notifiers: notifiers}
+	rl := &relay{
+		cfg:       cfg,
+		dedup:     newMemoryDedupStore(time.Minute),
+		pending:   newPendingKeys(),
+		queue:     newWorkQueue(cfg.QueueSize, false),
+		endpoints: []*endpoint{ep},
+		history:   newEventHistory(cfg.EventHistorySize),
+		stream:    newEventBroadcaster(),
+	}
+	rl.queue.Start(1, func(ctx context.Context, job issueJob) {
+		rl.processIssueJob(ctx, job)
+	}, rl.dropJob)
+	t.Cleanup(func() { rl.queue.Shutdown(context.Background()) })
+	return rl, ep
+}
+
+func postWebhook(rl *relay, ep *endpoint, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	rl.createIssueHandler(ep)(rec, req)
+	return rec
+}
+
+// waitForJobs waits until no job holds a dedup reservation.
+func waitForJobs(t *testing.T, rl *relay) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rl.pending.mu.Lock()
+		n := len(rl.pending.keys)
+		rl.pending.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d dedup keys still reserved", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRedeliveryWhileQueuedIsDeduplicated(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxEventAge = 0
+	notifier := &gatedNotifier{proceed: make(chan error)}
+	rl, ep := newQueueTestRelay(t, cfg, notifier)
+
+	if rec := postWebhook(rl, ep, fileUpdateWebhook); rec.Code != http.StatusAccepted {
+		t.Fatalf("first delivery: status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+	// The first job is still waiting on Linear, so nothing is recorded in
+	// the store yet; the reservation must catch the redelivery.
+	rec := postWebhook(rl, ep, fileUpdateWebhook)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "still in progress") {
+		t.Fatalf("redelivery while queued: status = %d, body %q, want a duplicate response", rec.Code, rec.Body)
+	}
+
+	notifier.proceed <- nil
+	waitForJobs(t, rl)
+	rec = postWebhook(rl, ep, fileUpdateWebhook)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "issue-1") {
+		t.Fatalf("redelivery after delivery: status = %d, body %q, want a duplicate of issue-1", rec.Code, rec.Body)
+	}
+}
+
+func TestFailedJobReleasesDedupKey(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxEventAge = 0
+	notifier := &gatedNotifier{proceed: make(chan error)}
+	rl, ep := newQueueTestRelay(t, cfg, notifier)
+
+	if rec := postWebhook(rl, ep, fileUpdateWebhook); rec.Code != http.StatusAccepted {
+		t.Fatalf("first delivery: status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+	notifier.proceed <- errors.New("linear: unavailable")
+	waitForJobs(t, rl)
+
+	// Nothing was created, so Figma's retry has to be processed again.
+	if rec := postWebhook(rl, ep, fileUpdateWebhook); rec.Code != http.StatusAccepted {
+		t.Fatalf("retry after failure: status = %d, body %q, want %d", rec.Code, rec.Body, http.StatusAccepted)
+	}
+	notifier.proceed <- nil
+	waitForJobs(t, rl)
+}
+
+func TestDroppedFlushReleasesDedupKeys(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxEventAge = 0
+	cfg.QueueSize = 1
+	notifier := &gatedNotifier{proceed: make(chan error)}
+	rl, ep := newQueueTestRelay(t, cfg, notifier)
+	update := func(ts string) string {
+		return strings.Replace(fileUpdateWebhook, "2026-01-02T15:04:05Z", ts, 1)
+	}
+
+	// One job blocks the worker and a second fills the queue.
+	if rec := postWebhook(rl, ep, update("2026-01-02T15:00:00Z")); rec.Code != http.StatusAccepted {
+		t.Fatalf("first delivery: status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+	for rl.queue.Len() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if rec := postWebhook(rl, ep, update("2026-01-02T15:01:00Z")); rec.Code != http.StatusAccepted {
+		t.Fatalf("second delivery: status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+
+	// The held update has nowhere to go when the debouncer flushes it.
+	rl.debouncer = newFileDebouncer(time.Hour, rl.flushDebounced)
+	held := update("2026-01-02T15:02:00Z")
+	if rec := postWebhook(rl, ep, held); rec.Code != http.StatusAccepted {
+		t.Fatalf("debounced delivery: status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+	rl.debouncer.Close()
+	notifier.proceed <- nil
+	notifier.proceed <- nil
+	waitForJobs(t, rl)
+
+	// Nothing was created for the dropped update, so Figma's retry has to
+	// be processed rather than ignored as in progress.
+	if rec := postWebhook(rl, ep, held); rec.Code != http.StatusAccepted {
+		t.Fatalf("retry after drop: status = %d, body %q, want %d", rec.Code, rec.Body, http.StatusAccepted)
+	}
+	notifier.proceed <- nil
+	waitForJobs(t, rl)
+}
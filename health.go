@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ready    atomic.Bool
+	draining atomic.Bool
+	inFlight atomic.Int64
+)
+
+func writeStatus(w http.ResponseWriter, code int, status string) {
+	writeJSON(w, code, map[string]string{"status": status})
+}
+
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":    "ok",
+		"draining":  draining.Load(),
+		"in_flight": inFlight.Load(),
+	})
+}
+
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if draining.Load() {
+		writeStatus(w, http.StatusServiceUnavailable, "draining")
+		return
+	}
+	if !ready.Load() {
+		writeStatus(w, http.StatusServiceUnavailable, "not ready")
+		return
+	}
+	writeStatus(w, http.StatusOK, "ok")
+}
+
+// trackInFlight counts requests that are being handled, so shutdown can
+// wait for them before closing the listener.
+func trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// waitForIdle blocks until no requests are in flight or ctx is done,
+// returning how many were still running.
+func waitForIdle(ctx context.Context) int64 {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		n := inFlight.Load()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return n
+		}
+	}
+}
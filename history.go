@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+type eventRecord struct {
+	Time      time.Time `json:"time"`
+	EventType string    `json:"event_type"`
+	FileKey   string    `json:"file_key"`
+	Outcome   string    `json:"outcome"`
+	IssueID   string    `json:"issue_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// eventHistory is a fixed-size ring buffer of recent webhook outcomes; once
+// full, each new record overwrites the oldest.
+type eventHistory struct {
+	mu      sync.Mutex
+	records []eventRecord
+	next    int
+	full    bool
+}
+
+func newEventHistory(size int) *eventHistory {
+	return &eventHistory{records: make([]eventRecord, size)}
+}
+
+func (h *eventHistory) add(rec eventRecord) {
+	if len(h.records) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records[h.next] = rec
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the buffered records, newest first.
+func (h *eventHistory) list() []eventRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := h.next
+	if h.full {
+		n = len(h.records)
+	}
+	out := make([]eventRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, h.records[(h.next-i+len(h.records))%len(h.records)])
+	}
+	return out
+}
+
+func (rl *relay) recordEvent(event FigmaWebhook, outcome, issueID string, err error) {
+	rec := eventRecord{
+		Time:      time.Now().UTC(),
+		EventType: event.EventType,
+		FileKey:   event.FileKey,
+		Outcome:   outcome,
+		IssueID:   issueID,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	rl.history.add(rec)
+	rl.stream.publish(rec)
+}
+
+func (rl *relay) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"events": rl.history.list()})
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type GraphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type LinearIssueInput struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	TeamID      string   `json:"teamId"`
+	Priority    int      `json:"priority,omitempty"`
+	LabelIDs    []string `json:"labelIds,omitempty"`
+	AssigneeID  string   `json:"assigneeId,omitempty"`
+	ProjectID   string   `json:"projectId,omitempty"`
+	StateID     string   `json:"stateId,omitempty"`
+	ParentID    string   `json:"parentId,omitempty"`
+	TemplateID  string   `json:"templateId,omitempty"`
+}
+
+type LinearCommentInput struct {
+	IssueID string `json:"issueId"`
+	Body    string `json:"body"`
+}
+
+type LinearComment struct {
+	ID string `json:"id"`
+}
+
+type LinearIssueRequest struct {
+	Input LinearIssueInput `json:"input"`
+}
+
+type LinearIssue struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// GraphQLResponse is the envelope Linear wraps every result in. Linear
+// reports validation failures with HTTP 200 and a non-empty Errors array.
+type GraphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type issueCreateData struct {
+	IssueCreate struct {
+		Issue LinearIssue `json:"issue"`
+	} `json:"issueCreate"`
+}
+
+type commentCreateData struct {
+	CommentCreate struct {
+		Comment LinearComment `json:"comment"`
+	} `json:"commentCreate"`
+}
+
+func buildCreateIssueReqBody(input LinearIssueInput) ([]byte, error) {
+	query := `
+        mutation IssueCreate($input: IssueCreateInput!) {
+            issueCreate(input: $input) {
+                issue {
+                    id
+                    title
+                    url
+                }
+            }
+        }
+    `
+
+	vars := map[string]interface{}{
+		"input": input,
+	}
+
+	reqBody := GraphQLRequest{
+		Query:     query,
+		Variables: vars,
+	}
+
+	return json.Marshal(reqBody)
+}
+
+func buildCreateCommentReqBody(input LinearCommentInput) ([]byte, error) {
+	query := `
+        mutation CommentCreate($input: CommentCreateInput!) {
+            commentCreate(input: $input) {
+                comment {
+                    id
+                }
+            }
+        }
+    `
+
+	vars := map[string]interface{}{
+		"input": input,
+	}
+
+	reqBody := GraphQLRequest{
+		Query:     query,
+		Variables: vars,
+	}
+
+	return json.Marshal(reqBody)
+}
+
+const defaultLinearAPIURL = "https://api.linear.app/graphql"
+
+// LinearConfig is everything a Linear API call needs besides the HTTP
+// client, so calls can be pointed at a test server without touching env.
+type LinearConfig struct {
+	APIURL     string
+	APIKey     string
+	AuthScheme string
+	// MaxRetries is the total number of attempts per request, including
+	// the first.
+	MaxRetries int
+	DryRun     bool
+}
+
+func (lc LinearConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("api_url", lc.APIURL),
+		slog.String("api_key", redact(lc.APIKey)),
+		slog.String("auth_scheme", lc.AuthScheme),
+		slog.Int("max_retries", lc.MaxRetries),
+		slog.Bool("dry_run", lc.DryRun),
+	)
+}
+
+// authorization returns the Authorization header value. Personal API keys
+// are sent as-is; OAuth access tokens need the Bearer scheme. With "auto",
+// the scheme is picked from Linear's token prefixes.
+func (lc LinearConfig) authorization() string {
+	switch lc.AuthScheme {
+	case "bearer":
+		return "Bearer " + lc.APIKey
+	case "auto":
+		if strings.HasPrefix(lc.APIKey, "lin_oauth_") {
+			return "Bearer " + lc.APIKey
+		}
+	}
+	return lc.APIKey
+}
+
+func (cfg *Config) linear() LinearConfig {
+	return LinearConfig{
+		APIURL:     cfg.LinearAPIURL,
+		APIKey:     cfg.LinearAPIKey,
+		AuthScheme: cfg.LinearAuthScheme,
+		MaxRetries: cfg.LinearMaxRetries,
+		DryRun:     cfg.DryRun,
+	}
+}
+
+var httpClient *http.Client
+
+// newHTTPClient builds the client shared by every outbound call. The proxy
+// comes from LINEAR_PROXY_URL if set, otherwise from the standard proxy
+// environment variables.
+func newHTTPClient(cfg *Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxConnsPerHost = cfg.LinearMaxConnsPerHost
+	transport.MaxIdleConnsPerHost = cfg.LinearMaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.LinearIdleConnTimeout
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.LinearHTTP2)
+	transport.Protocols = protocols
+	transport.ForceAttemptHTTP2 = cfg.LinearHTTP2
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.LinearProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.LinearProxyURL)
+		if err != nil {
+			return nil, errors.New("invalid LINEAR_PROXY_URL")
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	var rt http.RoundTripper = transport
+	if len(cfg.EgressHeaders) > 0 {
+		rt = &headerTransport{base: transport, headers: cfg.EgressHeaders}
+	}
+	return &http.Client{
+		Timeout:   cfg.LinearHTTPTimeout,
+		Transport: rt,
+	}, nil
+}
+
+// headerTransport adds EGRESS_HEADERS to each request. Headers the request
+// already sets, such as Authorization, are left alone.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// errLinearBusy is returned when every outbound Linear slot stays taken for
+// longer than LINEAR_ACQUIRE_TIMEOUT.
+var errLinearBusy = errors.New("timed out waiting for a free Linear request slot")
+
+type linearStatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *linearStatusError) Error() string {
+	return fmt.Sprintf("linear request failed, status: %s, body: %s", e.Status, e.Body)
+}
+
+type linearGraphQLError struct {
+	Message string
+}
+
+func (e *linearGraphQLError) Error() string {
+	return fmt.Sprintf("linear GraphQL error: %s", e.Message)
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || (code >= 500 && code <= 504)
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func createLinearIssue(ctx context.Context, client *http.Client, lc LinearConfig, input LinearIssueInput) (issue LinearIssue, err error) {
+	ctx, span := tracer.Start(ctx, "linear.issueCreate", trace.WithAttributes(
+		attribute.String("linear.team_id", input.TeamID),
+		attribute.Bool("relay.dry_run", lc.DryRun),
+	))
+	defer func() {
+		span.SetAttributes(attribute.String("linear.issue_id", issue.ID))
+		endSpan(span, err)
+	}()
+
+	if input.TeamID == "" {
+		return LinearIssue{}, fmt.Errorf("missing Linear team ID")
+	}
+
+	b, err := buildCreateIssueReqBody(input)
+	if err != nil {
+		return LinearIssue{}, fmt.Errorf("failed to build issueCreate request: %w", err)
+	}
+
+	if lc.DryRun {
+		loggerFrom(ctx).Info("DRY_RUN: skipping Linear API call", "team_id", input.TeamID, "title", input.Title)
+		loggerFrom(ctx).Debug("DRY_RUN: Linear request payload", "description", input.Description, "payload", string(b))
+		return LinearIssue{ID: "dry-run", Title: input.Title}, nil
+	}
+
+	var data issueCreateData
+	if err := executeLinearGraphQL(ctx, client, lc, b, &data); err != nil {
+		return LinearIssue{}, err
+	}
+
+	issue = data.IssueCreate.Issue
+	loggerFrom(ctx).Info("Created Linear issue", "issue_id", issue.ID, "title", issue.Title)
+	return issue, nil
+}
+
+// Retry waits start from retryBaseDelay and double after each failed
+// attempt, up to maxRetryDelay, before jitter is applied.
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	maxRetryDelay  = 30 * time.Second
+)
+
+// retryCeiling is the longest wait after the given failed attempt,
+// counting from 1.
+func retryCeiling(attempt int) time.Duration {
+	ceiling := retryBaseDelay
+	for i := 1; i < attempt && ceiling < maxRetryDelay; i++ {
+		ceiling *= 2
+	}
+	return min(ceiling, maxRetryDelay)
+}
+
+// retryDelay is how long to wait after the given failed attempt. A
+// Retry-After from Linear is honoured up to maxRetryDelay; otherwise the
+// wait is jittered below retryCeiling.
+func retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, maxRetryDelay)
+	}
+	return fullJitter(retryCeiling(attempt))
+}
+
+// fullJitter picks a random delay between zero and ceiling so workers that
+// failed together don't all retry at the same instant.
+func fullJitter(ceiling time.Duration) time.Duration {
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+func createLinearComment(ctx context.Context, client *http.Client, lc LinearConfig, input LinearCommentInput) (LinearComment, error) {
+	if input.IssueID == "" {
+		return LinearComment{}, fmt.Errorf("missing Linear issue ID")
+	}
+
+	b, err := buildCreateCommentReqBody(input)
+	if err != nil {
+		return LinearComment{}, fmt.Errorf("failed to build commentCreate request: %w", err)
+	}
+
+	if lc.DryRun {
+		loggerFrom(ctx).Info("DRY_RUN: skipping Linear API call", "issue_id", input.IssueID)
+		loggerFrom(ctx).Debug("DRY_RUN: Linear request payload", "body", input.Body, "payload", string(b))
+		return LinearComment{ID: "dry-run"}, nil
+	}
+
+	var data commentCreateData
+	if err := executeLinearGraphQL(ctx, client, lc, b, &data); err != nil {
+		return LinearComment{}, err
+	}
+
+	comment := data.CommentCreate.Comment
+	loggerFrom(ctx).Info("Created Linear comment", "comment_id", comment.ID, "issue_id", input.IssueID)
+	return comment, nil
+}
+
+// retriesExhaustedError is returned once every retry of a Linear request
+// has failed.
+type retriesExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *retriesExhaustedError) Error() string {
+	return fmt.Sprintf("giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *retriesExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// attemptsOf reports how many Linear requests were made before err.
+// Errors that were not retried count as one attempt.
+func attemptsOf(err error) int {
+	var exhausted *retriesExhaustedError
+	if errors.As(err, &exhausted) {
+		return exhausted.Attempts
+	}
+	return 1
+}
+
+func executeLinearGraphQL(ctx context.Context, client *http.Client, lc LinearConfig, b []byte, out any) error {
+	maxAttempts := lc.MaxRetries
+
+	for attempt := 1; ; attempt++ {
+		err := sendLinearRequest(ctx, client, lc, b, out)
+		if err == nil {
+			return nil
+		}
+
+		var gqlErr *linearGraphQLError
+		if errors.As(err, &gqlErr) {
+			return err
+		}
+
+		var retryAfter time.Duration
+		var statusErr *linearStatusError
+		if errors.As(err, &statusErr) {
+			if !isRetryableStatus(statusErr.StatusCode) {
+				return err
+			}
+			retryAfter = statusErr.RetryAfter
+		}
+		wait := retryDelay(attempt, retryAfter)
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if attempt >= maxAttempts {
+			return &retriesExhaustedError{Attempts: attempt, Err: err}
+		}
+
+		loggerFrom(ctx).Warn("Linear request failed, retrying", "attempt", attempt, "max_attempts", maxAttempts, "wait", wait.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func sendLinearRequest(ctx context.Context, client *http.Client, lc LinearConfig, b []byte, out any) (err error) {
+	ctx, span := tracer.Start(ctx, "linear.graphql", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("http.request.method", "POST")))
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", lc.APIURL, bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", lc.authorization())
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	linearLatency.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &linearStatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var result GraphQLResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to decode Linear response: %w", err)
+	}
+
+	if len(result.Errors) > 0 {
+		return &linearGraphQLError{Message: result.Errors[0].Message}
+	}
+
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("failed to decode Linear response data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const linearNamesQuery = `
+        query RelayNames {
+            teams(first: 250) {
+                nodes {
+                    id
+                    name
+                    key
+                }
+            }
+            issueLabels(first: 250) {
+                nodes {
+                    id
+                    name
+                }
+            }
+            projects(first: 250) {
+                nodes {
+                    id
+                }
+            }
+            workflowStates(first: 250) {
+                nodes {
+                    id
+                }
+            }
+        }
+    `
+
+type linearNamesData struct {
+	Teams struct {
+		Nodes []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"nodes"`
+	} `json:"teams"`
+	IssueLabels struct {
+		Nodes []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"issueLabels"`
+	Projects struct {
+		Nodes []struct {
+			ID string `json:"id"`
+		} `json:"nodes"`
+	} `json:"projects"`
+	WorkflowStates struct {
+		Nodes []struct {
+			ID string `json:"id"`
+		} `json:"nodes"`
+	} `json:"workflowStates"`
+}
+
+// linearNameCache maps Linear team and label names to their IDs so config
+// can refer to them by name. Lookups are case-insensitive; teams also match
+// on their short key (e.g. "ENG").
+type linearNameCache struct {
+	client *http.Client
+	lc     LinearConfig
+
+	mu       sync.RWMutex
+	teams    map[string]string
+	labels   map[string]string
+	projects map[string]bool
+	states   map[string]bool
+}
+
+func newLinearNameCache(client *http.Client, lc LinearConfig) *linearNameCache {
+	return &linearNameCache{client: client, lc: lc}
+}
+
+func (c *linearNameCache) refresh(ctx context.Context) error {
+	b, err := json.Marshal(GraphQLRequest{Query: linearNamesQuery})
+	if err != nil {
+		return err
+	}
+
+	var data linearNamesData
+	if err := executeLinearGraphQL(ctx, c.client, c.lc, b, &data); err != nil {
+		return fmt.Errorf("failed to load Linear teams and labels: %w", err)
+	}
+
+	teams := make(map[string]string)
+	for _, t := range data.Teams.Nodes {
+		teams[strings.ToLower(t.Name)] = t.ID
+		teams[strings.ToLower(t.Key)] = t.ID
+	}
+	labels := make(map[string]string)
+	for _, l := range data.IssueLabels.Nodes {
+		labels[strings.ToLower(l.Name)] = l.ID
+	}
+
+	projects := make(map[string]bool)
+	for _, p := range data.Projects.Nodes {
+		projects[p.ID] = true
+	}
+	states := make(map[string]bool)
+	for _, st := range data.WorkflowStates.Nodes {
+		states[st.ID] = true
+	}
+
+	c.mu.Lock()
+	c.teams, c.labels, c.projects, c.states = teams, labels, projects, states
+	c.mu.Unlock()
+
+	slog.Debug("Refreshed Linear name cache", "teams", len(data.Teams.Nodes), "labels", len(data.IssueLabels.Nodes))
+	return nil
+}
+
+func (c *linearNameCache) loaded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.teams != nil
+}
+
+func (c *linearNameCache) ensureLoaded(ctx context.Context) error {
+	if c.loaded() {
+		return nil
+	}
+	return c.refresh(ctx)
+}
+
+func (c *linearNameCache) resolveTeamID(ctx context.Context, name string) (string, error) {
+	if err := c.ensureLoaded(ctx); err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, ok := c.teams[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("no Linear team named %q", name)
+	}
+	return id, nil
+}
+
+// resolveLabelIDs maps label names to IDs. Names with no matching label are
+// returned in missing rather than treated as an error, so a renamed or
+// deleted label drops off the issue instead of blocking it.
+func (c *linearNameCache) resolveLabelIDs(ctx context.Context, names []string) (ids, missing []string, err error) {
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+	if err := c.ensureLoaded(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ids = make([]string, 0, len(names))
+	for _, name := range names {
+		id, ok := c.labels[strings.ToLower(name)]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, missing, nil
+}
+
+// checkIDs reports configured project and workflow state IDs that don't
+// exist in the workspace. Empty IDs are skipped.
+func (c *linearNameCache) checkIDs(projectID, stateID string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var errs []error
+	if projectID != "" && !c.projects[projectID] {
+		errs = append(errs, fmt.Errorf("no Linear project with ID %q", projectID))
+	}
+	if stateID != "" && !c.states[stateID] {
+		errs = append(errs, fmt.Errorf("no Linear workflow state with ID %q", stateID))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testTeamID = "3f2b1c4d-0000-4000-8000-123456789abc"
+
+func TestCreateLinearIssue(t *testing.T) {
+	const issueJSON = `{"data":{"issueCreate":{"issue":{"id":"issue-1","title":"Library published","url":"https://linear.app/test/issue/ENG-1"}}}}`
+	tests := []struct {
+		name string
+		// respond answers the given attempt, counting from 1.
+		respond      func(w http.ResponseWriter, attempt int32)
+		closed       bool
+		maxRetries   int
+		wantAttempts int32
+		wantIssue    string
+		wantErr      func(error) bool
+		minElapsed   time.Duration
+	}{
+		{
+			name:         "success",
+			respond:      func(w http.ResponseWriter, _ int32) { fmt.Fprint(w, issueJSON) },
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantIssue:    "issue-1",
+		},
+		{
+			name: "GraphQL errors aren't retried",
+			respond: func(w http.ResponseWriter, _ int32) {
+				fmt.Fprint(w, `{"data":null,"errors":[{"message":"Entity not found: Team"}]}`)
+			},
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantErr: func(err error) bool {
+				var gqlErr *linearGraphQLError
+				return errors.As(err, &gqlErr) && gqlErr.Message == "Entity not found: Team"
+			},
+		},
+		{
+			name: "401 isn't retried",
+			respond: func(w http.ResponseWriter, _ int32) {
+				http.Error(w, `{"errors":[{"message":"Authentication required"}]}`, http.StatusUnauthorized)
+			},
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantErr: func(err error) bool {
+				var statusErr *linearStatusError
+				return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized
+			},
+		},
+		{
+			name: "429 waits for Retry-After and retries",
+			respond: func(w http.ResponseWriter, attempt int32) {
+				if attempt == 1 {
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, "rate limited", http.StatusTooManyRequests)
+					return
+				}
+				fmt.Fprint(w, issueJSON)
+			},
+			maxRetries:   3,
+			wantAttempts: 2,
+			wantIssue:    "issue-1",
+			minElapsed:   time.Second,
+		},
+		{
+			name: "persistent 503 exhausts retries",
+			respond: func(w http.ResponseWriter, _ int32) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+			maxRetries:   2,
+			wantAttempts: 2,
+			wantErr: func(err error) bool {
+				var exhausted *retriesExhaustedError
+				return errors.As(err, &exhausted) && exhausted.Attempts == 2
+			},
+		},
+		{
+			name:       "transport failure exhausts retries",
+			closed:     true,
+			maxRetries: 2,
+			wantErr: func(err error) bool {
+				var exhausted *retriesExhaustedError
+				var statusErr *linearStatusError
+				return errors.As(err, &exhausted) && exhausted.Attempts == 2 && !errors.As(err, &statusErr)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := attempts.Add(1)
+				if got := r.Header.Get("Authorization"); got != "lin_api_test" {
+					t.Errorf("Authorization = %q, want the raw API key", got)
+				}
+				var req GraphQLRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Errorf("decoding request: %v", err)
+				}
+				tt.respond(w, attempt)
+			}))
+			defer srv.Close()
+			if tt.closed {
+				srv.Close()
+			}
+
+			lc := LinearConfig{APIURL: srv.URL, APIKey: "lin_api_test", AuthScheme: "raw", MaxRetries: tt.maxRetries}
+			start := time.Now()
+			issue, err := createLinearIssue(context.Background(), srv.Client(), lc, LinearIssueInput{Title: "Library published", TeamID: testTeamID})
+			elapsed := time.Since(start)
+
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("server saw %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("createLinearIssue() error = %v", err)
+			}
+			if tt.wantErr != nil && !tt.wantErr(err) {
+				t.Fatalf("createLinearIssue() error = %v (%T), not the expected kind", err, err)
+			}
+			if issue.ID != tt.wantIssue {
+				t.Errorf("issue ID = %q, want %q", issue.ID, tt.wantIssue)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("returned after %s, want at least %s", elapsed, tt.minElapsed)
+			}
+		})
+	}
+}
+
+func TestCreateLinearIssueStopsRetryingOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	lc := LinearConfig{APIURL: srv.URL, APIKey: "lin_api_test", MaxRetries: 3}
+	if _, err := createLinearIssue(ctx, srv.Client(), lc, LinearIssueInput{Title: "t", TeamID: testTeamID}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("createLinearIssue() error = %v, want the context's deadline", err)
+	}
+}
+
+func TestRetryCeiling(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 500 * time.Millisecond},
+		{2, time.Second},
+		{3, 2 * time.Second},
+		{6, 16 * time.Second},
+		{7, maxRetryDelay},
+		{64, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := retryCeiling(tt.attempt); got != tt.want {
+			t.Errorf("retryCeiling(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestFullJitterBounds(t *testing.T) {
+	for attempt := 1; attempt <= 12; attempt++ {
+		ceiling := min(retryBaseDelay<<(attempt-1), maxRetryDelay)
+		for range 1000 {
+			if d := fullJitter(retryCeiling(attempt)); d < 0 || d > ceiling {
+				t.Fatalf("attempt %d: delay %s outside [0, %s]", attempt, d, ceiling)
+			}
+		}
+	}
+	if d := fullJitter(0); d != 0 {
+		t.Errorf("fullJitter(0) = %s, want 0", d)
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	// http.ProxyFromEnvironment reads the environment once per process, so
+	// HTTPS_PROXY is set before any client resolves a proxy.
+	t.Setenv("HTTPS_PROXY", "http://env-proxy.internal:3128")
+	t.Setenv("NO_PROXY", "")
+
+	tests := []struct {
+		name     string
+		override string
+		want     string
+	}{
+		{"HTTPS_PROXY", "", "http://env-proxy.internal:3128"},
+		{"LINEAR_PROXY_URL overrides HTTPS_PROXY", "http://linear-proxy.internal:8080", "http://linear-proxy.internal:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.LinearProxyURL = tt.override
+			client, err := newHTTPClient(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, defaultLinearAPIURL, nil)
+			proxy, err := client.Transport.(*http.Transport).Proxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proxy == nil || proxy.String() != tt.want {
+				t.Errorf("proxy for %s = %v, want %s", defaultLinearAPIURL, proxy, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPClientInvalidProxy(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.LinearProxyURL = "http://proxy.internal:port"
+	if _, err := newHTTPClient(cfg); err == nil {
+		t.Fatal("newHTTPClient() accepted an unparseable LINEAR_PROXY_URL")
+	}
+}
+
+// BenchmarkCreateLinearIssue creates issues concurrently against a TLS
+// mock of Linear, over HTTP/1.1 and HTTP/2. Run with -cpu to vary the
+// number of concurrent callers.
+func BenchmarkCreateLinearIssue(b *testing.B) {
+	for _, bm := range []struct {
+		name      string
+		http2     bool
+		wantProto int
+	}{
+		{"HTTP/1.1", false, 1},
+		{"HTTP/2", true, 2},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			var wrongProto atomic.Int32
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.ProtoMajor != bm.wantProto {
+					wrongProto.Add(1)
+				}
+				fmt.Fprint(w, `{"data":{"issueCreate":{"issue":{"id":"issue-1","title":"Library published","url":"https://linear.app/test/issue/ENG-1"}}}}`)
+			}))
+			srv.EnableHTTP2 = true
+			srv.StartTLS()
+			defer srv.Close()
+
+			cfg := defaultConfig()
+			cfg.LinearHTTP2 = bm.http2
+			client, err := newHTTPClient(cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			client.Transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+			lc := LinearConfig{APIURL: srv.URL, APIKey: "lin_api_test", MaxRetries: 1}
+			input := LinearIssueInput{Title: "Library published", Description: "body", TeamID: testTeamID}
+			ctx := withLogger(context.Background(), slog.New(slog.DiscardHandler))
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := createLinearIssue(ctx, client, lc, input); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.StopTimer()
+			if n := wrongProto.Load(); n > 0 {
+				b.Fatalf("%d requests didn't use HTTP/%d", n, bm.wantProto)
+			}
+		})
+	}
+}
+
+func TestRetryDelayCapsRetryAfter(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{5 * time.Second, 5 * time.Second},
+		{maxRetryDelay, maxRetryDelay},
+		{time.Hour, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(1, tt.retryAfter); got != tt.want {
+			t.Errorf("retryDelay(1, %s) = %s, want %s", tt.retryAfter, got, tt.want)
+		}
+	}
+	if d := retryDelay(3, 0); d < 0 || d > retryCeiling(3) {
+		t.Errorf("retryDelay(3, 0) = %s, want jitter within [0, %s]", d, retryCeiling(3))
+	}
+}
@@ -1,181 +1,140 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-
-	"github.com/joho/godotenv"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-type GraphQLRequest struct {
-	Query     string                 `json:"query"`
-	Variables map[string]interface{} `json:"variables,omitempty"`
-}
-
-type User struct {
-	ID     string `json:"id"`
-	Handle string `json:"handle"`
-}
-
-type Component struct {
-	Key       string `json:"key"`
-	Name      string `json:"name"`
-	Desc      string `json:"description"`
-	UpdatedAt string `json:"updated_at"`
-}
-
-type Library struct {
-	ID                  string      `json:"id"`
-	Name                string      `json:"name"`
-	FileKey             string      `json:"file_key"`
-	PublishedComponents []Component `json:"published_components"`
-}
-
-type FigmaWebhook struct {
-	EventType   string `json:"event_type"`
-	FileKey     string `json:"file_key"`
-	Timestamp   string `json:"timestamp"`
-	TriggeredBy string `json:"triggered_by"`
-	Webhooks    []struct {
-		ID       string `json:"id"`
-		TeamID   string `json:"team_id"`
-		Endpoint string `json:"endpoint"`
-	} `json:"webhooks"`
-}
-
-type LinearIssueInput struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	TeamID      string `json:"teamId"`
-}
-
-type LinearIssueRequest struct {
-	Input LinearIssueInput `json:"input"`
-}
-
-func buildCreateIssueReqBody(title, description, teamId string) ([]byte, error) {
-	query := `
-        mutation IssueCreate($input: IssueCreateInput!) {
-            issueCreate(input: $input) {
-                issue {
-                    id
-                    title
-                }
-            }
-        }
-    `
-
-	vars := map[string]interface{}{
-		"input": map[string]string{
-			"title":       title,
-			"description": description,
-			"teamId":      teamId,
-		},
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		fmt.Println(currentVersion())
+		return
 	}
 
-	reqBody := GraphQLRequest{
-		Query:     query,
-		Variables: vars,
+	setupLogging()
+	if err := loadDotenv(); err != nil {
+		fatal("Invalid configuration", "error", err)
 	}
 
-	return json.Marshal(reqBody)
-}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			fatal("Replay failed", "error", err)
+		}
+		return
+	}
 
-func createLinearIssue(title, description string) error {
+	registerMetrics()
 
-	var linearToken = os.Getenv("LINEAR_API_KEY")
-	var linearTeamID = os.Getenv("LINEAR_TEAM_ID")
+	v := currentVersion()
+	slog.Info("Starting relay", "version", v.Version, "commit", v.Commit, "build_date", v.BuildDate)
 
-	if linearToken == "" || linearTeamID == "" {
-		return fmt.Errorf("missing LINEAR_API_KEY or LINEAR_TEAM_ID in env")
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-
-	b, err := buildCreateIssueReqBody(title, description, linearTeamID)
-
-	req, err := http.NewRequest("POST", "https://api.linear.app/graphql", bytes.NewBuffer(b))
 	if err != nil {
-		return err
+		fatal("Invalid configuration", "error", err)
 	}
+	logLevel.Set(cfg.logLevel)
+	slog.Info("Effective configuration", "config", cfg)
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", linearToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	httpClient, err = newHTTPClient(cfg)
 	if err != nil {
-		return err
+		fatal("Invalid configuration", "error", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to create issue, status: %s, body: %s", resp.Status, string(body))
+	if cfg.validateOnly {
+		// Leave room for every attempt to time out, with the longest wait
+		// between each.
+		attempts := time.Duration(max(cfg.LinearMaxRetries, 1))
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.LinearHTTPTimeout*attempts+maxRetryDelay*(attempts-1))
+		defer cancel()
+		if err := runValidate(ctx, cfg); err != nil {
+			fatal("Validation failed", "error", err)
+		}
+		slog.Info("Validation passed")
+		return
 	}
 
-	log.Printf("Created Linear issue:", resp.Body)
-	return nil
-
-}
-
-func createIssueHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	if cfg.DryRun {
+		slog.Warn("DRY_RUN is enabled, issues will be logged but not created")
 	}
 
-	var webhook FigmaWebhook
-	body, err := io.ReadAll(r.Body)
+	shutdownTracing, err := setupTracing(context.Background())
 	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
+		fatal("Failed to initialize tracing", "error", err)
 	}
-	defer r.Body.Close()
 
-	if err := json.Unmarshal(body, &webhook); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
+	rl, err := newRelay(cfg)
+	if err != nil {
+		fatal("Failed to initialize relay", "error", err)
 	}
 
-	log.Printf("Received Figma webhook: %+v", webhook)
+	ready.Store(true)
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           rl.routes(),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	server.RegisterOnShutdown(rl.stream.Close)
 
-	if webhook.EventType == "LIBRARY_PUBLISH" {
-		title := fmt.Sprintf("Figma Library Published: %s", webhook.FileKey)
-		description := fmt.Sprintf("The Figma file with key %s has published a new library at %s.", webhook.FileKey, webhook.Timestamp)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-		if err := createLinearIssue(title, description); err != nil {
-			http.Error(w, "Failed to create Linear issue: "+err.Error(), http.StatusInternalServerError)
+	serverErr := make(chan error, 1)
+	go func() {
+		if cfg.tlsEnabled() {
+			slog.Info("Server starting", "port", cfg.Port, "tls", true, "cert_file", cfg.TLSCertFile)
+			serverErr <- server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
 			return
 		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Linear issue created successfully"))
-	} else {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Event type not handled"))
+		slog.Info("Server starting", "port", cfg.Port, "tls", false)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		fatal("Server failed to start", "error", err)
+	case <-ctx.Done():
 	}
-}
 
-func init() {
-	_ = godotenv.Load()
-}
+	slog.Info("Shutdown signal received, draining connections", "timeout", cfg.ShutdownTimeout.String())
 
-func main() {
+	// Fail readiness first so the load balancer stops routing here, then
+	// let requests already being handled finish before closing listeners.
+	draining.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	http.HandleFunc("/create-issue", createIssueHandler)
+	if n := waitForIdle(shutdownCtx); n > 0 {
+		slog.Warn("Requests still in flight at drain deadline", "in_flight", n)
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "80"
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Graceful shutdown did not complete", "error", err)
 	}
 
-	log.Printf("Server starting on port %s", port)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelDrain()
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatal("Server failed to start:", err)
+	rl.Close(drainCtx)
+
+	if err := shutdownTracing(drainCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
 	}
+
+	slog.Info("Shutdown complete")
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// markdownEscaper backslash-escapes the characters that start inline
+// markdown constructs: emphasis, code spans, links, images and raw HTML.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	">", `\>`,
+	"|", `\|`,
+	"~", `\~`,
+	"!", `\!`,
+)
+
+// escapeMarkdown makes a user-provided string safe to interpolate into a
+// single line of markdown. Newlines are folded into spaces so a value
+// can't end the surrounding paragraph or list item.
+func escapeMarkdown(s string) string {
+	return escapeLineStart(markdownEscaper.Replace(oneLine(s)))
+}
+
+// oneLine collapses all runs of whitespace, including newlines, into
+// single spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// escapeLineStart escapes the characters that only mean something at the
+// start of a line: headings, list markers, rules and setext underlines.
+func escapeLineStart(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if trimmed == "" {
+		return line
+	}
+	indent := line[:len(line)-len(trimmed)]
+	switch trimmed[0] {
+	case '#', '-', '+', '=':
+		return indent + `\` + trimmed
+	}
+	// Ordered list markers: one or more digits followed by "." or ")".
+	i := 0
+	for i < len(trimmed) && trimmed[i] >= '0' && trimmed[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(trimmed) && (trimmed[i] == '.' || trimmed[i] == ')') {
+		return indent + trimmed[:i] + `\` + trimmed[i:]
+	}
+	return line
+}
+
+// quoteMarkdown renders multi-line text, such as a comment, as a block
+// quote, escaping each line so its content is shown literally.
+func quoteMarkdown(s string) string {
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n")), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			lines[i] = ">"
+			continue
+		}
+		lines[i] = "> " + escapeLineStart(markdownEscaper.Replace(line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fencedBlock wraps s in a code fence longer than any run of backticks
+// inside it, so the content can never close the fence early.
+func fencedBlock(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+			continue
+		}
+		run = 0
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + "\n" + s + "\n" + fence
+}
+
+// renderDescription builds the markdown description for an event: the
+// event-specific body, a link to the file and a fenced metadata section.
+func renderDescription(webhook FigmaWebhook) string {
+	_, body := eventHandlers[webhook.EventType](webhook)
+
+	var sb strings.Builder
+	sb.WriteString(body)
+	if webhook.FileKey != "" {
+		fmt.Fprintf(&sb, "\n\n[Open %s in Figma](%s)", escapeMarkdown(webhook.fileLabel()), figmaFileURL(webhook.FileKey))
+	}
+
+	meta := []string{"event: " + webhook.EventType}
+	if webhook.FileKey != "" {
+		meta = append(meta, "file_key: "+webhook.FileKey)
+	}
+	if webhook.FileName != "" {
+		meta = append(meta, "file_name: "+oneLine(webhook.FileName))
+	}
+	if webhook.Timestamp != "" {
+		meta = append(meta, "timestamp: "+webhook.Timestamp)
+	}
+	if webhook.TriggeredBy != (User{}) {
+		meta = append(meta, "triggered_by: "+oneLine(webhook.TriggeredBy.String()))
+	}
+	sb.WriteString("\n\n" + fencedBlock(strings.Join(meta, "\n")))
+	return sb.String()
+}
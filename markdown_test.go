@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"run `rm -rf` now", "run \\`rm -rf\\` now"},
+		{"```go", "\\`\\`\\`go"},
+		{"# Heading", `\# Heading`},
+		{"## Heading", `\## Heading`},
+		{"1. First", `1\. First`},
+		{"12) Twelfth", `12\) Twelfth`},
+		{"- item", `\- item`},
+		{"+ item", `\+ item`},
+		{"   - indented item", `\- indented item`},
+		{"v1.2 button", "v1.2 button"},
+		{"Button [primary]", `Button \[primary\]`},
+		{"[Click](https://evil.example)", `\[Click\](https://evil.example)`},
+		{"![x](https://evil.example/p.png)", `\!\[x\](https://evil.example/p.png)`},
+		{"*bold* _it_ ~strike~", `\*bold\* \_it\_ \~strike\~`},
+		{"<script>", `\<script\>`},
+		{`C:\path`, `C:\\path`},
+		{"line one\n# line two", `line one # line two`},
+	}
+	for _, tt := range tests {
+		if got := escapeMarkdown(tt.in); got != tt.want {
+			t.Errorf("escapeMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteMarkdown(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"single line", "Looks good", "> Looks good"},
+		{
+			name: "multi-line",
+			in:   "First line\r\n\r\n# not a heading\n- not a list\n1. not ordered\n> not nested",
+			want: "> First line\n>\n> \\# not a heading\n> \\- not a list\n> 1\\. not ordered\n> \\> not nested",
+		},
+		{"inline markup", "see `code` and [link](x)", "> see \\`code\\` and \\[link\\](x)"},
+		{"surrounding blank lines", "\n\n  padded  \n\n", "> padded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteMarkdown(tt.in); got != tt.want {
+				t.Errorf("quoteMarkdown(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFencedBlock(t *testing.T) {
+	tests := []struct {
+		in, wantFence string
+	}{
+		{"event: FILE_UPDATE", "```"},
+		{"a `code span` inside", "```"},
+		{"file_name: ```oops```", "````"},
+		{"`````five", "``````"},
+	}
+	for _, tt := range tests {
+		got := fencedBlock(tt.in)
+		want := tt.wantFence + "\n" + tt.in + "\n" + tt.wantFence
+		if got != want {
+			t.Errorf("fencedBlock(%q) = %q, want %q", tt.in, got, want)
+		}
+	}
+}
+
+func TestRenderDescriptionEscapesNames(t *testing.T) {
+	webhook := FigmaWebhook{
+		EventType: "LIBRARY_PUBLISH",
+		FileKey:   "abc123",
+		FileName:  "Icons [v2]",
+		LibraryPublishEvent: LibraryPublishEvent{
+			CreatedComponents: []Component{{Name: "Button [primary]", Desc: "# Use for main actions"}},
+		},
+	}
+	got := renderDescription(webhook)
+
+	for _, want := range []string{
+		`- **Button \[primary\]** (added): \# Use for main actions`,
+		`[Open Icons \[v2\] in Figma](https://figma.com/file/abc123)`,
+		// The code fence shows the raw name.
+		"file_name: Icons [v2]",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("description doesn't contain %q:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "]("); n != 1 {
+		t.Errorf("description has %d links, want only the file link:\n%s", n, got)
+	}
+}
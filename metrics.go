@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	webhooksReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "relay_webhooks_received_total",
+		Help: "Figma webhooks received, by event type.",
+	}, []string{"event_type"})
+
+	webhooksDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "relay_webhooks_dropped_total",
+		Help: "Figma webhooks dropped without creating an issue, by reason.",
+	}, []string{"reason"})
+
+	linearIssuesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "relay_linear_issues_created_total",
+		Help: "Linear issues created successfully.",
+	})
+
+	linearFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "relay_linear_failures_total",
+		Help: "Failed Linear issue creations, by reason.",
+	}, []string{"reason"})
+
+	linearBreakerState = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "relay_linear_circuit_state",
+		Help: "Linear circuit breaker state: 0 closed, 1 open, 2 half-open.",
+	})
+
+	linearRateLimitWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "relay_linear_rate_limit_wait_seconds",
+		Help:    "Time spent waiting on the per-team Linear rate limiter.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	linearLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "relay_linear_request_duration_seconds",
+		Help:    "Latency of individual Linear API requests.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func registerMetrics() {
+	prometheus.MustRegister(webhooksReceived, webhooksDropped, linearIssuesCreated, linearFailures, linearBreakerState, linearRateLimitWait, linearLatency)
+}
+
+func failureReason(err error) string {
+	var statusErr *linearStatusError
+	var gqlErr *linearGraphQLError
+
+	switch {
+	case errors.Is(err, errLinearBusy):
+		return "concurrency_limit"
+	case errors.Is(err, errCircuitOpen):
+		return "circuit_open"
+	case errors.As(err, &gqlErr):
+		return "graphql_error"
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("http_%d", statusErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return "timeout"
+	default:
+		return "network"
+	}
+}
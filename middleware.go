@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+type middleware func(http.Handler) http.Handler
+
+// chain wraps h so the first middleware listed is the outermost.
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// withRequestLogging assigns each request an ID, stores a logger carrying it
+// in the request context and logs the outcome once the handler returns.
+func withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := newRequestID()
+		w.Header().Set(requestIDHeader, requestID)
+		logger := loggerFrom(r.Context()).With("request_id", requestID)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(withLogger(r.Context(), logger)))
+
+		logger.Info("Request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+// recoverPanics turns a handler panic into a 500 so one bad payload can't
+// take the server down.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			loggerFrom(r.Context()).Error("Handler panicked", "panic", v, "stack", string(debug.Stack()))
+			if rec.status == 0 {
+				writeJSONError(rec, http.StatusInternalServerError, errCodeInternal, "Internal server error")
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// methodNotAllowed answers every request with a 405 naming the allowed
+// method. The mux routes a path here when no method-specific pattern
+// matched.
+func methodNotAllowed(allow string) http.Handler {
+	if allow == http.MethodGet {
+		allow += ", " + http.MethodHead
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+	})
+}
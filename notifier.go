@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifier delivers a rendered Figma event to a single downstream target.
+type Notifier interface {
+	Notify(ctx context.Context, event FigmaWebhook, title, description string) error
+}
+
+// issueCreator is implemented by notifiers that create a trackable issue.
+// The relay records the returned ID so redeliveries can point back at it.
+type issueCreator interface {
+	CreateIssue(ctx context.Context, event FigmaWebhook, title, description string) (string, error)
+}
+
+// linearShared is the Linear-facing state every endpoint shares, since they
+// all talk to the same Linear.
+type linearShared struct {
+	breaker    *circuitBreaker
+	teamLimits *teamRateLimiter
+	audit      *auditLog
+}
+
+func newNotifiers(cfg *Config, client *http.Client, shared linearShared) ([]Notifier, error) {
+	var notifiers []Notifier
+	switch cfg.Target {
+	case "github":
+		notifiers = append(notifiers, &GitHubNotifier{cfg: cfg, client: client})
+	default:
+		linear, err := newLinearNotifier(cfg, client, shared)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, linear)
+	}
+
+	if cfg.SlackWebhookURL != "" {
+		notifiers = append(notifiers, &SlackNotifier{webhookURL: cfg.SlackWebhookURL, client: client})
+	}
+	return notifiers, nil
+}
+
+// notifyAll fans the event out to every notifier concurrently so a slow or
+// failing target doesn't hold up the others. It returns the first issue ID
+// created, if any, along with every error encountered.
+func notifyAll(ctx context.Context, notifiers []Notifier, event FigmaWebhook, title, description string) (string, error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		issueID string
+		errs    []error
+	)
+
+	for _, n := range notifiers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var id string
+			var err error
+			if creator, ok := n.(issueCreator); ok {
+				id, err = creator.CreateIssue(ctx, event, title, description)
+			} else {
+				err = n.Notify(ctx, event, title, description)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if id != "" && issueID == "" {
+				issueID = id
+			}
+		}()
+	}
+	wg.Wait()
+
+	return issueID, errors.Join(errs...)
+}
+
+type LinearNotifier struct {
+	cfg       *Config
+	client    *http.Client
+	slots     chan struct{}
+	names     *linearNameCache
+	breaker   *circuitBreaker
+	publishes *publishCounter
+	cooldown  *issueCooldown
+	audit     *auditLog
+	limits    *teamRateLimiter
+}
+
+func newLinearNotifier(cfg *Config, client *http.Client, shared linearShared) (*LinearNotifier, error) {
+	n := &LinearNotifier{
+		cfg:     cfg,
+		client:  client,
+		slots:   make(chan struct{}, cfg.LinearMaxConcurrency),
+		breaker: shared.breaker,
+		audit:   shared.audit,
+		limits:  shared.teamLimits,
+	}
+	if cfg.EscalationWindow > 0 {
+		n.publishes = newPublishCounter(cfg.EscalationWindow)
+	}
+	if cfg.PerFileCooldown > 0 {
+		n.cooldown = newIssueCooldown(cfg.PerFileCooldown)
+	}
+	if !cfg.usesLinearNames() {
+		return n, nil
+	}
+
+	n.names = newLinearNameCache(client, cfg.linear())
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.LinearHTTPTimeout)
+	defer cancel()
+	if err := n.checkNames(ctx); err != nil {
+		return nil, err
+	}
+
+	go runCleanup(cfg.LinearNameRefresh, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.LinearHTTPTimeout)
+		defer cancel()
+		if err := n.names.refresh(ctx); err != nil {
+			slog.Warn("Failed to refresh Linear name cache, keeping previous entries", "error", err)
+		}
+	})
+	return n, nil
+}
+
+// checkNames resolves every configured team and label name once at startup
+// so a typo fails fast instead of on the first webhook.
+func (n *LinearNotifier) checkNames(ctx context.Context) error {
+	if err := n.names.refresh(ctx); err != nil {
+		return err
+	}
+
+	var errs []error
+	if n.cfg.LinearTeamID == "" {
+		if _, err := n.names.resolveTeamID(ctx, n.cfg.LinearTeamName); err != nil {
+			errs = append(errs, fmt.Errorf("LINEAR_TEAM_NAME: %w", err))
+		}
+	}
+	if err := n.names.checkIDs(n.cfg.LinearProjectID, n.cfg.LinearStateID); err != nil {
+		errs = append(errs, err)
+	}
+	for eventType, settings := range n.cfg.EventSettings {
+		if _, missing, err := n.names.resolveLabelIDs(ctx, settings.LabelNames); err != nil {
+			errs = append(errs, fmt.Errorf("%s_LABELS: %w", eventType, err))
+		} else if len(missing) > 0 {
+			slog.Warn("Configured Linear labels not found, they will be skipped", "event_type", eventType, "labels", missing)
+		}
+		if err := n.names.checkIDs(settings.ProjectID, settings.StateID); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", eventType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// acquire takes one of the outbound call slots, waiting at most
+// LINEAR_ACQUIRE_TIMEOUT so a stalled Linear can't pile up workers forever.
+func (n *LinearNotifier) acquire(ctx context.Context) error {
+	select {
+	case n.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(n.cfg.LinearAcquireTimeout)
+	defer timer.Stop()
+
+	loggerFrom(ctx).Debug("Waiting for a Linear concurrency slot", "in_use", len(n.slots))
+	select {
+	case n.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return errLinearBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *LinearNotifier) release() {
+	<-n.slots
+}
+
+func (n *LinearNotifier) CreateIssue(ctx context.Context, event FigmaWebhook, title, description string) (string, error) {
+	if n.cfg.LinearMode == "comment" {
+		return n.comment(ctx, n.cfg.LinearCommentIssueID, n.cfg.LinearTeamID, title, description)
+	}
+	// Count the publish before the cooldown check, so publishes that only
+	// comment on an existing issue still count towards escalation.
+	publishes := n.recordPublish(event)
+	if n.cooldown == nil || event.FileKey == "" {
+		return n.newIssue(ctx, event, title, description, publishes)
+	}
+
+	key := cooldownKey(event.FileKey, event.EventType)
+	existing, active, err := n.cooldown.claim(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("linear: waiting for issue cooldown: %w", err)
+	}
+	if active {
+		loggerFrom(ctx).Info("File is in issue cooldown, commenting on existing issue", "issue_id", existing, "cooldown", n.cfg.PerFileCooldown.String())
+		if n.escalated(publishes) {
+			loggerFrom(ctx).Info("Noting escalation on issue in cooldown", "issue_id", existing, "publishes", publishes, "window", n.cfg.EscalationWindow.String())
+			description = fmt.Sprintf("**Escalated:** this file has been published %d times in the last %s.\n\n%s", publishes, n.cfg.EscalationWindow, description)
+		}
+		return n.comment(ctx, existing, teamForEvent(ctx, n.cfg, event), title, description)
+	}
+
+	issueID, err := n.newIssue(ctx, event, title, description, publishes)
+	n.cooldown.release(key, issueID)
+	return issueID, err
+}
+
+// recordPublish counts a library publish of the event's file and returns
+// how many fall within ESCALATION_WINDOW. It returns 0 for other events
+// or when escalation is off.
+func (n *LinearNotifier) recordPublish(event FigmaWebhook) int {
+	if n.publishes == nil || event.EventType != "LIBRARY_PUBLISH" {
+		return 0
+	}
+	return n.publishes.record(event.FileKey)
+}
+
+// escalated reports whether a file's recent publishes exceed
+// ESCALATION_THRESHOLD.
+func (n *LinearNotifier) escalated(publishes int) bool {
+	return n.publishes != nil && publishes > n.cfg.EscalationThreshold
+}
+
+// newIssue files a new issue for the event, with sub-issues for library
+// publishes when enabled. publishes is the count from recordPublish.
+func (n *LinearNotifier) newIssue(ctx context.Context, event FigmaWebhook, title, description string, publishes int) (string, error) {
+	settings := n.cfg.EventSettings[event.EventType]
+
+	input := LinearIssueInput{
+		Title:       title,
+		Description: description,
+		TeamID:      teamForEvent(ctx, n.cfg, event),
+		Priority:    settings.Priority,
+		LabelIDs:    settings.LabelIDs,
+		AssigneeID:  assigneeForEvent(ctx, n.cfg, event),
+		ProjectID:   cmp.Or(settings.ProjectID, n.cfg.LinearProjectID),
+		StateID:     cmp.Or(settings.StateID, n.cfg.LinearStateID),
+		TemplateID:  settings.TemplateID,
+	}
+	if n.escalated(publishes) {
+		input.Priority = escalatePriority(input.Priority, n.cfg.EscalationPriority)
+		loggerFrom(ctx).Info("Escalating priority for frequently published file", "publishes", publishes, "window", n.cfg.EscalationWindow.String(), "priority", input.Priority)
+	}
+	if n.names != nil {
+		if err := n.resolveNames(ctx, &input, settings); err != nil {
+			return "", fmt.Errorf("linear: %w", err)
+		}
+	}
+
+	issue, err := n.create(ctx, eventSource(event), input)
+	if err != nil {
+		return "", fmt.Errorf("linear: %w", err)
+	}
+
+	if event.EventType == "LIBRARY_PUBLISH" && n.cfg.PublishSubIssues {
+		if err := n.createSubIssues(ctx, event, input, issue.ID); err != nil {
+			return issue.ID, fmt.Errorf("linear: %w", err)
+		}
+	}
+	return issue.ID, nil
+}
+
+// eventSource describes the event an issue was filed for in its audit log
+// entry.
+func eventSource(event FigmaWebhook) auditEntry {
+	return auditEntry{Fingerprint: dedupKey(event), EventType: event.EventType, FileKey: event.FileKey}
+}
+
+// create files one issue and records it in the audit log against source.
+// A failed audit write is logged rather than returned, since the issue
+// already exists.
+func (n *LinearNotifier) create(ctx context.Context, source auditEntry, input LinearIssueInput) (LinearIssue, error) {
+	if err := n.waitForTeam(ctx, input.TeamID); err != nil {
+		return LinearIssue{}, err
+	}
+
+	var issue LinearIssue
+	err := n.call(ctx, func() (err error) {
+		issue, err = createLinearIssue(ctx, n.client, n.cfg.linear(), input)
+		return err
+	})
+	if err != nil {
+		return LinearIssue{}, err
+	}
+
+	linearIssuesCreated.Inc()
+	stats.issuesCreated.Add(1)
+
+	entry := source
+	entry.Time = time.Now().UTC()
+	entry.Status = auditCreated
+	entry.Endpoint = n.cfg.endpointName
+	entry.TeamID = input.TeamID
+	entry.IssueID = issue.ID
+	entry.IssueURL = issue.URL
+	entry.ParentID = input.ParentID
+	if err := n.audit.write(entry); err != nil {
+		loggerFrom(ctx).Error("Failed to write audit log entry", "issue_id", issue.ID, "error", err)
+	}
+	return issue, nil
+}
+
+// createTestIssue files an issue for /admin/test-issue under the same
+// limits, circuit breaker and audit log as issues filed for webhooks.
+// fingerprint identifies the request in the audit log.
+func (n *LinearNotifier) createTestIssue(ctx context.Context, fingerprint string, input LinearIssueInput) (LinearIssue, error) {
+	return n.create(ctx, auditEntry{Fingerprint: fingerprint, EventType: testIssueEventType}, input)
+}
+
+// comment appends the event to an existing issue instead of filing a new
+// one: the standing LINEAR_COMMENT_ISSUE_ID issue, or the issue still in
+// PER_FILE_COOLDOWN. The returned ID is that issue's, so dedup still points
+// redeliveries at it.
+func (n *LinearNotifier) comment(ctx context.Context, issueID, teamID, title, description string) (string, error) {
+	input := LinearCommentInput{
+		IssueID: issueID,
+		Body:    fmt.Sprintf("**%s**\n\n%s", title, description),
+	}
+	if err := n.waitForTeam(ctx, teamID); err != nil {
+		return "", fmt.Errorf("linear: %w", err)
+	}
+	err := n.call(ctx, func() error {
+		_, err := createLinearComment(ctx, n.client, n.cfg.linear(), input)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("linear: %w", err)
+	}
+	return input.IssueID, nil
+}
+
+// waitForTeam holds the call until the team's rate limiter has a token. It
+// runs before taking a concurrency slot so a throttled team doesn't hold
+// slots other teams could use.
+func (n *LinearNotifier) waitForTeam(ctx context.Context, teamID string) error {
+	waited, err := n.limits.Wait(ctx, teamID)
+	if n.limits != nil {
+		linearRateLimitWait.Observe(waited.Seconds())
+	}
+	if err != nil {
+		return fmt.Errorf("waiting for team %s rate limit: %w", teamID, err)
+	}
+	if waited > time.Second {
+		loggerFrom(ctx).Info("Waited on Linear team rate limit", "team_id", teamID, "waited", waited.String())
+	}
+	return nil
+}
+
+// call runs one Linear request under the concurrency limit and circuit
+// breaker, counting failures.
+func (n *LinearNotifier) call(ctx context.Context, fn func() error) error {
+	if err := n.acquire(ctx); err != nil {
+		loggerFrom(ctx).Warn("Could not acquire a Linear concurrency slot", "limit", cap(n.slots), "wait", n.cfg.LinearAcquireTimeout.String(), "error", err)
+		linearFailures.WithLabelValues(failureReason(err)).Inc()
+		return err
+	}
+	defer n.release()
+
+	if err := n.breaker.allow(); err != nil {
+		linearFailures.WithLabelValues(failureReason(err)).Inc()
+		return err
+	}
+	err := fn()
+	n.breaker.record(err)
+	if err != nil {
+		linearFailures.WithLabelValues(failureReason(err)).Inc()
+	}
+	return err
+}
+
+// createSubIssues files one child issue per changed component under the
+// parent. A failed child doesn't stop the rest; the error names every
+// component that couldn't be created.
+func (n *LinearNotifier) createSubIssues(ctx context.Context, event FigmaWebhook, parent LinearIssueInput, parentID string) error {
+	changes := event.LibraryPublishEvent.changes()
+
+	var failed []string
+	var errs []error
+	for _, c := range changes {
+		child := parent
+		child.Title = fmt.Sprintf("Component %s: %s", c.Change, c.Name)
+		child.Description = fmt.Sprintf("The component %s was %s in %s.", escapeMarkdown(c.Name), c.Change, escapeMarkdown(event.fileLabel()))
+		if c.Desc != "" {
+			child.Description += "\n\n" + escapeMarkdown(c.Desc)
+		}
+		child.ParentID = parentID
+		child.TemplateID = ""
+
+		if _, err := n.create(ctx, eventSource(event), child); err != nil {
+			loggerFrom(ctx).Error("Failed to create component sub-issue", "component", c.Name, "parent_id", parentID, "error", err)
+			failed = append(failed, c.Name)
+			errs = append(errs, err)
+		}
+	}
+
+	loggerFrom(ctx).Info("Created component sub-issues", "parent_id", parentID, "created", len(changes)-len(failed), "failed", len(failed))
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d sub-issues failed (%s): %w", len(failed), len(changes), strings.Join(failed, ", "), errors.Join(errs...))
+	}
+	return nil
+}
+
+func (n *LinearNotifier) resolveNames(ctx context.Context, input *LinearIssueInput, settings EventSettings) error {
+	if input.TeamID == "" {
+		teamID, err := n.names.resolveTeamID(ctx, n.cfg.LinearTeamName)
+		if err != nil {
+			return err
+		}
+		input.TeamID = teamID
+	}
+
+	labelIDs, missing, err := n.names.resolveLabelIDs(ctx, settings.LabelNames)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		loggerFrom(ctx).Warn("Skipping unknown Linear labels", "labels", missing)
+	}
+	input.LabelIDs = append(slices.Clone(input.LabelIDs), labelIDs...)
+	return nil
+}
+
+func (n *LinearNotifier) Notify(ctx context.Context, event FigmaWebhook, title, description string) error {
+	_, err := n.CreateIssue(ctx, event, title, description)
+	return err
+}
+
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, event FigmaWebhook, title, description string) error {
+	b, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", title, description),
+	})
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.webhookURL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("slack: webhook returned %s: %s", resp.Status, string(body))
+	}
+
+	loggerFrom(ctx).Info("Posted Slack notification")
+	return nil
+}
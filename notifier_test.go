@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLinear stands in for Linear's GraphQL API. It records every issue
+// and comment it's asked to create and answers with sequential IDs.
+type fakeLinear struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	issues   []LinearIssueInput
+	comments []LinearCommentInput
+}
+
+func newFakeLinear(t *testing.T) *fakeLinear {
+	t.Helper()
+	f := &fakeLinear{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeLinear) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string `json:"query"`
+		Variables struct {
+			Input json.RawMessage `json:"input"`
+		} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.Contains(req.Query, "issueCreate"):
+		var input LinearIssueInput
+		json.Unmarshal(req.Variables.Input, &input)
+		f.issues = append(f.issues, input)
+		id := fmt.Sprintf("issue-%d", len(f.issues))
+		fmt.Fprintf(w, `{"data":{"issueCreate":{"issue":{"id":%q,"title":%q,"url":"https://linear.app/test/issue/%s"}}}}`, id, input.Title, id)
+	case strings.Contains(req.Query, "commentCreate"):
+		var input LinearCommentInput
+		json.Unmarshal(req.Variables.Input, &input)
+		f.comments = append(f.comments, input)
+		fmt.Fprintf(w, `{"data":{"commentCreate":{"comment":{"id":"comment-%d"}}}}`, len(f.comments))
+	default:
+		http.Error(w, "unexpected query", http.StatusBadRequest)
+	}
+}
+
+func TestCreateIssueEscalatesAcrossCooldown(t *testing.T) {
+	linear := newFakeLinear(t)
+	cfg := defaultConfig()
+	cfg.LinearAPIURL = linear.URL
+	cfg.LinearAPIKey = "lin_api_test"
+	cfg.LinearTeamID = testTeamID
+	cfg.PerFileCooldown = time.Hour
+	cfg.EscalationWindow = time.Hour
+	cfg.EscalationThreshold = 2
+	cfg.EscalationPriority = 2
+
+	n, err := newLinearNotifier(cfg, linear.Client(), linearShared{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := FigmaWebhook{EventType: "LIBRARY_PUBLISH", FileKey: "abc123"}
+	publish := func() string {
+		t.Helper()
+		id, err := n.CreateIssue(context.Background(), event, "Library published", "body")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	// The first publish files an issue; the next two fall in its cooldown
+	// and comment on it. Only the third is over the threshold.
+	first := publish()
+	for range 2 {
+		if id := publish(); id != first {
+			t.Fatalf("publish in cooldown returned %q, want existing issue %q", id, first)
+		}
+	}
+	if len(linear.issues) != 1 || len(linear.comments) != 2 {
+		t.Fatalf("got %d issues and %d comments, want 1 and 2", len(linear.issues), len(linear.comments))
+	}
+	if linear.issues[0].Priority != 0 {
+		t.Errorf("first issue priority = %d, want unescalated", linear.issues[0].Priority)
+	}
+	if strings.Contains(linear.comments[0].Body, "Escalated") {
+		t.Errorf("comment under the threshold notes escalation: %q", linear.comments[0].Body)
+	}
+	if !strings.Contains(linear.comments[1].Body, "**Escalated:** this file has been published 3 times") {
+		t.Errorf("comment over the threshold doesn't note escalation: %q", linear.comments[1].Body)
+	}
+
+	// Once the cooldown ends the next issue carries the escalated priority,
+	// because the publishes that only commented were still counted.
+	n.cooldown.mu.Lock()
+	n.cooldown.issues[cooldownKey(event.FileKey, event.EventType)].created = time.Now().Add(-2 * time.Hour)
+	n.cooldown.mu.Unlock()
+	if id := publish(); id == first {
+		t.Fatalf("publish after cooldown commented on %q, want a new issue", id)
+	}
+	if len(linear.issues) != 2 {
+		t.Fatalf("got %d issues, want 2", len(linear.issues))
+	}
+	if got := linear.issues[1].Priority; got != cfg.EscalationPriority {
+		t.Errorf("issue after cooldown priority = %d, want escalated to %d", got, cfg.EscalationPriority)
+	}
+}
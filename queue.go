@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type issueJob struct {
+	logger    *slog.Logger
+	endpoint  *endpoint
+	dedupKeys []string
+	event     FigmaWebhook
+	// trace is the span of the webhook request that queued the job.
+	trace trace.SpanContext
+}
+
+type workQueue struct {
+	jobs      chan issueJob
+	blockFull bool
+	wg        sync.WaitGroup
+
+	// mu guards closed so Enqueue never sends on a closed channel; closing
+	// releases producers blocked on a full queue once shutdown begins.
+	mu      sync.RWMutex
+	closed  bool
+	closing chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newWorkQueue(size int, blockFull bool) *workQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workQueue{
+		jobs:      make(chan issueJob, size),
+		blockFull: blockFull,
+		closing:   make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start runs workers that hand each job to process. Jobs still queued once
+// the shutdown deadline has passed go to drop instead.
+func (q *workQueue) Start(workers int, process func(context.Context, issueJob), drop func(issueJob)) {
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for job := range q.jobs {
+				if q.ctx.Err() != nil {
+					job.logger.Error("Dropping queued webhook, shutdown deadline exceeded")
+					drop(job)
+					continue
+				}
+				process(q.ctx, job)
+			}
+		}()
+	}
+}
+
+// Enqueue reports whether the job was accepted. Under the block policy it
+// waits for room until ctx is done; otherwise a full queue drops the job.
+func (q *workQueue) Enqueue(ctx context.Context, job issueJob) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return false
+	}
+
+	if q.blockFull {
+		select {
+		case q.jobs <- job:
+			return true
+		case <-ctx.Done():
+			return false
+		case <-q.closing:
+			return false
+		}
+	}
+
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *workQueue) Len() int {
+	return len(q.jobs)
+}
+
+// Shutdown stops accepting jobs and waits for queued and in-flight jobs to
+// finish. If ctx expires first, in-flight work is cancelled and whatever is
+// still queued is dropped.
+func (q *workQueue) Shutdown(ctx context.Context) error {
+	close(q.closing)
+
+	q.mu.Lock()
+	q.closed = true
+	close(q.jobs)
+	q.mu.Unlock()
+
+	slog.Info("Draining work queue", "queued", q.Len())
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		remaining := q.Len()
+		q.cancel()
+		<-done
+		return fmt.Errorf("work queue drain timed out with %d jobs still queued", remaining)
+	}
+}
+
+// dropJob releases the dedup keys of a job that will never be processed,
+// so Figma's retry of the event is accepted instead of ignored.
+func (rl *relay) dropJob(job issueJob) {
+	rl.pending.release(job.dedupKeys...)
+}
+
+// processIssueJob builds and delivers the issue for one job, returning the
+// created issue ID and any delivery error.
+func (rl *relay) processIssueJob(ctx context.Context, job issueJob) (issueID string, err error) {
+	ctx = withLogger(ctx, job.logger)
+	defer rl.pending.release(job.dedupKeys...)
+
+	// The job runs after the webhook response was sent, so its span
+	// continues the handler's trace rather than the worker's context.
+	ctx, span := tracer.Start(trace.ContextWithSpanContext(ctx, job.trace), "relay.process_issue",
+		trace.WithAttributes(
+			attribute.String("figma.event_type", job.event.EventType),
+			attribute.String("figma.file_key", job.event.FileKey),
+			attribute.Int("relay.dedup_keys", len(job.dedupKeys)),
+		))
+	defer func() {
+		span.SetAttributes(attribute.String("linear.issue_id", issueID))
+		endSpan(span, err)
+	}()
+
+	meta := rl.enrichFromFigma(ctx, &job.event)
+
+	title, _ := eventHandlers[job.event.EventType](job.event)
+	description := renderDescription(job.event)
+	if rl.cfg.AttachThumbnail {
+		if meta.ThumbnailURL != "" {
+			description += fmt.Sprintf("\n\n![%s thumbnail](%s)", escapeMarkdown(job.event.fileLabel()), meta.ThumbnailURL)
+		} else {
+			job.logger.Debug("No Figma thumbnail available for file")
+		}
+	}
+	title, description, err = applyIssueTemplates(rl.cfg, job.event, title, description)
+	if err != nil {
+		job.logger.Error("Failed to render issue templates", "error", err)
+		stats.failures.Add(1)
+		rl.recordEvent(job.event, "failed", "", err)
+		return "", err
+	}
+
+	description = rl.finishDescription(ctx, job.event, description)
+
+	issueID, err = notifyAll(ctx, job.endpoint.notifiers, job.event, title, description)
+	if err != nil {
+		job.logger.Error("Failed to deliver notifications", "error", err)
+	}
+
+	// Record the event once an issue exists, even if a secondary target
+	// failed, so a redelivery doesn't create a duplicate issue.
+	if err == nil || issueID != "" {
+		for _, key := range job.dedupKeys {
+			if err := rl.dedup.Record(key, issueID); err != nil {
+				job.logger.Error("Failed to record processed event", "error", err)
+			}
+		}
+	}
+
+	switch {
+	case err == nil:
+		job.logger.Info("Webhook processed", "issue_id", issueID)
+		rl.recordEvent(job.event, "processed", issueID, nil)
+	case issueID != "":
+		rl.recordEvent(job.event, "partial", issueID, err)
+	default:
+		stats.failures.Add(1)
+		rl.recordEvent(job.event, "failed", "", err)
+		rl.recordFailure(ctx, job, err)
+	}
+	return issueID, err
+}
+
+// finishDescription fits description within MAX_DESCRIPTION_CHARS and
+// appends the source footer when ISSUE_SOURCE_FOOTER is on. The footer is
+// kept intact by truncating only the body before it.
+func (rl *relay) finishDescription(ctx context.Context, event FigmaWebhook, description string) string {
+	var footer string
+	limit := rl.cfg.MaxDescriptionChars
+	if rl.cfg.SourceFooter {
+		footer = sourceFooter(event)
+		limit = max(limit-utf8.RuneCountInString(footer), utf8.RuneCountInString(truncatedSuffix))
+	}
+	if truncated, ok := truncateRunes(description, limit); ok {
+		loggerFrom(ctx).Warn("Truncated issue description", "length", utf8.RuneCountInString(description), "max", rl.cfg.MaxDescriptionChars)
+		description = truncated
+	}
+	return description + footer
+}
+
+// recordFailure writes an undelivered event to the audit log and the
+// dead-letter file with enough context to debug it or replay it once the
+// target recovers.
+func (rl *relay) recordFailure(ctx context.Context, job issueJob, err error) {
+	event := job.event
+	entry := auditEntry{
+		Time:        time.Now().UTC(),
+		Status:      auditFailed,
+		Fingerprint: dedupKey(event),
+		Endpoint:    job.endpoint.name,
+		EventType:   event.EventType,
+		FileKey:     event.FileKey,
+		Attempts:    attemptsOf(err),
+		Error:       err.Error(),
+		Event:       &event,
+	}
+	if err := rl.audit.write(entry); err != nil {
+		loggerFrom(ctx).Error("Failed to write audit log entry", "error", err)
+	}
+	if err := rl.deadLetters.write(entry); err != nil {
+		loggerFrom(ctx).Error("Failed to write dead-letter entry", "error", err)
+	} else if rl.deadLetters != nil {
+		loggerFrom(ctx).Warn("Wrote undelivered event to dead-letter file", "path", rl.cfg.DeadLetterPath)
+	}
+}
+
+// enrichFromFigma fills in the file name from the Figma API when a token is
+// configured and returns the file metadata. The lookup gets at most
+// FIGMA_ENRICH_TIMEOUT; failures and timeouts are logged and the event is
+// processed as-is, so a Figma outage never blocks issue creation.
+func (rl *relay) enrichFromFigma(ctx context.Context, event *FigmaWebhook) figmaFileMeta {
+	if rl.cfg.FigmaAPIToken == "" || event.FileKey == "" {
+		return figmaFileMeta{}
+	}
+
+	ctx, span := tracer.Start(ctx, "figma.enrich", trace.WithAttributes(attribute.String("figma.file_key", event.FileKey)))
+	ctx, cancel := context.WithTimeout(ctx, rl.cfg.FigmaEnrichTimeout)
+	defer cancel()
+	meta, err := rl.fetchFigmaFileMeta(ctx, event.FileKey)
+	endSpan(span, err)
+	if err != nil {
+		loggerFrom(ctx).Warn("Failed to fetch Figma file metadata, continuing without it",
+			"timed_out", errors.Is(err, context.DeadlineExceeded), "error", err)
+		return figmaFileMeta{}
+	}
+	if meta.Name != "" {
+		event.FileName = meta.Name
+	}
+	return meta
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type keyedLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// fileRateLimiter keeps an independent token bucket per Figma file key so
+// one noisy file can't crowd out events from the others.
+type fileRateLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*keyedLimiter
+}
+
+func newFileRateLimiter(perMinute, burst int) *fileRateLimiter {
+	l := &fileRateLimiter{
+		limit:    rate.Limit(float64(perMinute) / 60),
+		burst:    burst,
+		limiters: make(map[string]*keyedLimiter),
+	}
+	go runCleanup(time.Minute, l.cleanup)
+	return l
+}
+
+func (l *fileRateLimiter) Allow(fileKey string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.limiters[fileKey]
+	if !ok {
+		entry = &keyedLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[fileKey] = entry
+	}
+	entry.lastSeen = time.Now()
+
+	return entry.limiter.Allow()
+}
+
+// cleanup forgets files that have been quiet long enough for their bucket
+// to refill completely, since a fresh limiter would behave identically.
+func (l *fileRateLimiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	idle := time.Duration(float64(l.burst)/float64(l.limit)*float64(time.Second)) + time.Minute
+	for key, entry := range l.limiters {
+		if time.Since(entry.lastSeen) > idle {
+			delete(l.limiters, key)
+		}
+	}
+}
+
+// teamRateLimiter paces outbound Linear calls with one token bucket per
+// team, so each workspace's API quota is respected independently. Unlike
+// fileRateLimiter it waits for a token instead of refusing, since the
+// calls are the relay's own and already queued.
+type teamRateLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+func newTeamRateLimiter(perMinute, burst int) *teamRateLimiter {
+	return &teamRateLimiter{
+		limit:    rate.Limit(float64(perMinute) / 60),
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// Wait blocks until teamID has a token or ctx is done, returning how long
+// it waited. A nil limiter never waits.
+func (l *teamRateLimiter) Wait(ctx context.Context, teamID string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	limiter, ok := l.limiters[teamID]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[teamID] = limiter
+	}
+	l.mu.Unlock()
+
+	start := time.Now()
+	err := limiter.Wait(ctx)
+	return time.Since(start), err
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// runReplay implements `relay replay [flags] <auditfile>`. It re-drives
+// every event the audit log (or a dead-letter file) records as failed,
+// skipping events that a later entry or the dedup store shows were
+// delivered after all. Events are replayed in file order, one at a time,
+// and the event age limit does not apply.
+func runReplay(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: relay replay [flags] <auditfile>")
+	}
+	path := args[len(args)-1]
+
+	entries, err := readAuditEntries(path)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(args[:len(args)-1])
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	logLevel.Set(cfg.logLevel)
+	httpClient, err = newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	rl, err := newRelay(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize relay: %w", err)
+	}
+	ctx := context.Background()
+	defer rl.Close(ctx)
+
+	endpoints := make(map[string]*endpoint, len(rl.endpoints))
+	for _, ep := range rl.endpoints {
+		endpoints[ep.name] = ep
+	}
+
+	var replayed, failed, skipped int
+	for _, entry := range pendingReplays(entries) {
+		logger := slog.Default().With("fingerprint", entry.Fingerprint, "event_type", entry.EventType, "file_key", entry.FileKey)
+
+		ep, ok := endpoints[entry.Endpoint]
+		if !ok {
+			logger.Warn("Skipping event for unknown endpoint", "endpoint", entry.Endpoint)
+			skipped++
+			continue
+		}
+		key := ep.dedupKey(*entry.Event)
+		if issueID, ok := rl.dedup.Seen(key); ok {
+			logger.Info("Skipping event already delivered", "issue_id", issueID)
+			skipped++
+			continue
+		}
+
+		job := issueJob{logger: logger, endpoint: ep, dedupKeys: []string{key}, event: *entry.Event}
+		if _, err := rl.processIssueJob(ctx, job); err != nil {
+			failed++
+			continue
+		}
+		replayed++
+	}
+
+	slog.Info("Replay complete", "replayed", replayed, "failed", failed, "skipped", skipped)
+	if failed > 0 {
+		return fmt.Errorf("%d events failed again", failed)
+	}
+	return nil
+}
+
+func readAuditEntries(path string) ([]auditEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// pendingReplays returns the first failed entry for each event that has
+// no created entry anywhere in the log. Events are told apart by endpoint
+// as well as fingerprint, since the same event relayed for two endpoints
+// is delivered separately.
+func pendingReplays(entries []auditEntry) []auditEntry {
+	type eventKey struct{ endpoint, fingerprint string }
+	created := make(map[eventKey]bool)
+	for _, entry := range entries {
+		if entry.Status == auditCreated {
+			created[eventKey{entry.Endpoint, entry.Fingerprint}] = true
+		}
+	}
+
+	seen := make(map[eventKey]bool)
+	var pending []auditEntry
+	for _, entry := range entries {
+		if entry.Status != auditFailed || entry.Event == nil {
+			continue
+		}
+		key := eventKey{entry.Endpoint, entry.Fingerprint}
+		if created[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		pending = append(pending, entry)
+	}
+	return pending
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestPendingReplaysScopesByEndpoint(t *testing.T) {
+	event := &FigmaWebhook{EventType: "FILE_UPDATE", FileKey: "abc123", Timestamp: "2026-01-02T15:04:05Z"}
+	fingerprint := dedupKey(*event)
+	entries := []auditEntry{
+		{Status: auditFailed, Fingerprint: fingerprint, Event: event},
+		{Status: auditFailed, Fingerprint: fingerprint, Endpoint: "marketing", Event: event},
+		{Status: auditFailed, Fingerprint: fingerprint, Endpoint: "marketing", Event: event},
+		// Only the default endpoint's delivery went through in the end.
+		{Status: auditCreated, Fingerprint: fingerprint, IssueID: "issue-1"},
+	}
+
+	pending := pendingReplays(entries)
+	if len(pending) != 1 || pending[0].Endpoint != "marketing" {
+		t.Fatalf("pendingReplays() = %+v, want the marketing endpoint's failure once", pending)
+	}
+}
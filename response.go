@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Stable error codes returned in JSON error bodies. Clients match on these,
+// so existing values must not change.
+const (
+	errCodeMethodNotAllowed = "method_not_allowed"
+	errCodeNotFound         = "not_found"
+	errCodeBodyTooLarge     = "body_too_large"
+	errCodeReadFailed       = "read_failed"
+	errCodeEmptyBody        = "empty_body"
+	errCodeInvalidSignature = "invalid_signature"
+	errCodeInvalidJSON      = "invalid_json"
+	errCodeInvalidTimestamp = "invalid_timestamp"
+	errCodeStaleEvent       = "stale_event"
+	errCodeInvalidRequest   = "invalid_request"
+	errCodeRateLimited      = "rate_limited"
+	errCodeQueueFull        = "queue_full"
+	errCodeInProgress       = "in_progress"
+	errCodeUnauthorized     = "unauthorized"
+	errCodeAdminDisabled    = "admin_disabled"
+	errCodeOriginNotAllowed = "origin_not_allowed"
+	errCodeNotConfigured    = "not_configured"
+	errCodeFigmaFailed      = "figma_failed"
+	errCodeLinearFailed     = "linear_failed"
+	errCodeInternal         = "internal_error"
+)
+
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Fields maps request fields to what was wrong with each, for
+	// validation failures.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorBody{Error: errorDetail{Code: code, Message: message}})
+}
+
+// writeValidationError answers 400 listing every invalid field at once, so
+// a client can fix them all in one round trip.
+func writeValidationError(w http.ResponseWriter, fields map[string]string) {
+	writeJSON(w, http.StatusBadRequest, errorBody{Error: errorDetail{Code: errCodeInvalidRequest, Message: "Invalid request", Fields: fields}})
+}
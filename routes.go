@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// routes builds the server's mux. Each route is registered for a single
+// method; any other method on the same path gets a JSON 405, and unknown
+// paths get a logged JSON 404.
+func (rl *relay) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	route := func(method, path string, h http.Handler) {
+		mux.Handle(method+" "+path, h)
+		mux.Handle(path, chain(methodNotAllowed(method), withRequestLogging))
+	}
+	logged := func(h http.Handler, mws ...middleware) http.Handler {
+		return chain(h, append([]middleware{trackInFlight, withRequestLogging, recoverPanics}, mws...)...)
+	}
+	// Admin routes also accept cross-origin browser calls from
+	// ADMIN_CORS_ORIGINS. Webhook ingress is server-to-server and gets no
+	// CORS headers.
+	cors := newCORSPolicy(rl.cfg.AdminCORSOrigins)
+	admin := func(method, path string, h http.Handler) {
+		route(method, path, cors.allow(h))
+		if cors != nil {
+			mux.Handle(http.MethodOptions+" "+path, chain(cors.preflight(method), withRequestLogging))
+		}
+	}
+
+	for _, ep := range rl.endpoints {
+		route(http.MethodPost, ep.path, logged(rl.createIssueHandler(ep),
+			verifyWebhook(FigmaVerifier{Passcodes: ep.cfg.webhookPasscodes()}, rl.cfg.MaxBodyBytes)))
+	}
+
+	route(http.MethodGet, "/healthz", http.HandlerFunc(healthzHandler))
+	route(http.MethodGet, "/readyz", http.HandlerFunc(readyzHandler))
+	route(http.MethodGet, "/metrics", promhttp.Handler())
+	route(http.MethodGet, "/version", http.HandlerFunc(versionHandler))
+	route(http.MethodGet, "/stats", http.HandlerFunc(rl.statsHandler))
+
+	admin(http.MethodGet, "/admin/events", logged(rl.requireAdmin(rl.eventsHandler)))
+	// Streams stay open indefinitely, so they aren't counted as in-flight
+	// requests; shutdown closes them instead.
+	admin(http.MethodGet, "/admin/stream", chain(rl.requireAdmin(rl.streamHandler), withRequestLogging, recoverPanics))
+	admin(http.MethodPost, "/admin/test-issue", logged(rl.requireAdmin(rl.testIssueHandler)))
+	admin(http.MethodPost, "/admin/register-webhook", logged(rl.requireAdmin(rl.registerWebhookHandler)))
+
+	mux.Handle("/", chain(http.HandlerFunc(notFoundHandler), withRequestLogging))
+	return mux
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	loggerFrom(r.Context()).Warn("No route for request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Not found")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+)
+
+func teamForEvent(ctx context.Context, cfg *Config, event FigmaWebhook) string {
+	if cfg.RoutingMode == "team" {
+		figmaTeamID := event.figmaTeamID()
+		if teamID, ok := cfg.TeamTeamMap[figmaTeamID]; ok && teamID != "" {
+			loggerFrom(ctx).Info("Routing Figma team to mapped Linear team", "team_id", teamID)
+			return teamID
+		}
+	} else if teamID, ok := cfg.FileTeamMap[event.FileKey]; ok && teamID != "" {
+		loggerFrom(ctx).Info("Routing file to mapped Linear team", "team_id", teamID)
+		return teamID
+	}
+
+	loggerFrom(ctx).Info("No team mapping for event, using default Linear team", "team_id", cfg.LinearTeamID)
+	return cfg.LinearTeamID
+}
+
+// assigneeForEvent picks the Linear assignee for an event. A comment is
+// assigned to the first @-mentioned user with a mapping, falling back to
+// the comment's author like every other event.
+func assigneeForEvent(ctx context.Context, cfg *Config, event FigmaWebhook) string {
+	if event.EventType == "FILE_COMMENT" {
+		for _, user := range event.FileCommentEvent.mentionedUsers() {
+			if assigneeID := mappedAssignee(cfg, user); assigneeID != "" {
+				loggerFrom(ctx).Info("Assigning issue to mentioned Linear user", "figma_user", user.String(), "assignee_id", assigneeID)
+				return assigneeID
+			}
+		}
+	}
+	return assigneeForUser(ctx, cfg, event.TriggeredBy)
+}
+
+func assigneeForUser(ctx context.Context, cfg *Config, user User) string {
+	if assigneeID := mappedAssignee(cfg, user); assigneeID != "" {
+		loggerFrom(ctx).Info("Assigning issue to mapped Linear user", "figma_user", user.String(), "assignee_id", assigneeID)
+		return assigneeID
+	}
+
+	loggerFrom(ctx).Debug("No Linear user mapping for Figma user, leaving issue unassigned", "figma_user", user.String())
+	return ""
+}
+
+// mappedAssignee looks a Figma user up in FIGMA_USER_MAP by ID, then by
+// handle.
+func mappedAssignee(cfg *Config, user User) string {
+	for _, key := range []string{user.ID, user.Handle} {
+		if key == "" {
+			continue
+		}
+		if assigneeID := cfg.UserAssigneeMap[key]; assigneeID != "" {
+			return assigneeID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// relayStats keeps the numbers served at /stats, for deployments that don't
+// scrape Prometheus. Counts start from zero at process start.
+type relayStats struct {
+	started       time.Time
+	webhooks      atomic.Int64
+	issuesCreated atomic.Int64
+	failures      atomic.Int64
+	byEventType   sync.Map // event type -> *atomic.Int64
+}
+
+var stats = relayStats{started: time.Now()}
+
+func (s *relayStats) webhookReceived(eventType string) {
+	s.webhooks.Add(1)
+	counter, _ := s.byEventType.LoadOrStore(eventType, new(atomic.Int64))
+	counter.(*atomic.Int64).Add(1)
+}
+
+func (s *relayStats) eventTypeCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	s.byEventType.Range(func(k, v any) bool {
+		counts[k.(string)] = v.(*atomic.Int64).Load()
+		return true
+	})
+	return counts
+}
+
+type statsResponse struct {
+	StartedAt     time.Time        `json:"started_at"`
+	UptimeSeconds int64            `json:"uptime_seconds"`
+	Webhooks      int64            `json:"webhooks_received"`
+	ByEventType   map[string]int64 `json:"webhooks_by_event_type"`
+	IssuesCreated int64            `json:"issues_created"`
+	Failures      int64            `json:"failures"`
+	QueueDepth    int              `json:"queue_depth"`
+}
+
+func (rl *relay) statsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, statsResponse{
+		StartedAt:     stats.started.UTC(),
+		UptimeSeconds: int64(time.Since(stats.started).Seconds()),
+		Webhooks:      stats.webhooks.Load(),
+		ByEventType:   stats.eventTypeCounts(),
+		IssuesCreated: stats.issuesCreated.Load(),
+		Failures:      stats.failures.Load(),
+		QueueDepth:    rl.queue.Len(),
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	streamClientBuffer = 64
+	streamHeartbeat    = 15 * time.Second
+)
+
+// eventBroadcaster fans event records out to /admin/stream clients. Each
+// client gets its own buffered channel; a client that falls a full buffer
+// behind is disconnected rather than allowed to block event processing.
+type eventBroadcaster struct {
+	mu      sync.Mutex
+	clients map[chan eventRecord]struct{}
+	closed  bool
+}
+
+func newEventBroadcaster() *eventBroadcaster {
+	return &eventBroadcaster{clients: make(map[chan eventRecord]struct{})}
+}
+
+// subscribe returns a channel of records that is closed when the client is
+// dropped or the broadcaster shuts down, and a func to unsubscribe early.
+func (b *eventBroadcaster) subscribe() (<-chan eventRecord, func()) {
+	ch := make(chan eventRecord, streamClientBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.clients[ch] = struct{}{}
+	return ch, func() { b.remove(ch) }
+}
+
+func (b *eventBroadcaster) remove(ch chan eventRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.clients[ch]; ok {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+func (b *eventBroadcaster) publish(rec eventRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.clients {
+		select {
+		case ch <- rec:
+		default:
+			delete(b.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close disconnects every client. It runs when the server starts shutting
+// down, since open streams would otherwise hold shutdown until it times out.
+func (b *eventBroadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.clients {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+// streamHandler serves GET /admin/stream as Server-Sent Events, one "event"
+// message per recorded webhook outcome.
+func (rl *relay) streamHandler(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// Streams outlive WRITE_TIMEOUT by design.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Streaming is not supported")
+		return
+	}
+
+	events, unsubscribe := rl.stream.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	logger := loggerFrom(r.Context())
+	logger.Info("Event stream client connected")
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.Info("Event stream client disconnected")
+			return
+		case rec, ok := <-events:
+			if !ok {
+				logger.Info("Event stream closed, client too slow or server shutting down")
+				return
+			}
+			data, _ := json.Marshal(rec)
+			if _, err := fmt.Fprintf(w, "event: event\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+func parseIssueTemplate(name, raw string) (*template.Template, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	// Executing against a zero event catches references to fields that
+	// don't exist on FigmaWebhook, which Parse alone won't report.
+	if err := tmpl.Execute(new(strings.Builder), FigmaWebhook{}); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return tmpl, nil
+}
+
+func renderTemplate(tmpl *template.Template, webhook FigmaWebhook, fallback string) (string, error) {
+	if tmpl == nil {
+		return fallback, nil
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, webhook); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", tmpl.Name(), err)
+	}
+	return sb.String(), nil
+}
+
+func applyIssueTemplates(cfg *Config, webhook FigmaWebhook, title, description string) (string, string, error) {
+	title, err := renderTemplate(cfg.titleTemplate, webhook, title)
+	if err != nil {
+		return "", "", err
+	}
+
+	description, err = renderTemplate(cfg.descriptionTemplate, webhook, description)
+	if err != nil {
+		return "", "", err
+	}
+
+	return wrapTitle(title, cfg.TitlePrefix, cfg.TitleSuffix), description, nil
+}
+
+// wrapTitle joins prefix, title and suffix with single spaces, skipping
+// whichever are empty.
+func wrapTitle(title, prefix, suffix string) string {
+	var parts []string
+	for _, p := range []string{prefix, title, suffix} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
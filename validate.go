@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+const linearViewerQuery = `query RelayViewer { viewer { id name email } }`
+
+type linearViewerData struct {
+	Viewer struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"viewer"`
+}
+
+type figmaMe struct {
+	ID     string `json:"id"`
+	Handle string `json:"handle"`
+	Email  string `json:"email"`
+}
+
+// runValidate backs --validate: with the config already loaded and
+// validated, it checks that each Linear API key and the Figma token are
+// accepted, reporting every failure rather than stopping at the first.
+func runValidate(ctx context.Context, cfg *Config) error {
+	var errs []error
+
+	if cfg.Target == "linear" || cfg.Target == "" {
+		keys := []*Config{cfg}
+		for _, ec := range cfg.Endpoints {
+			keys = append(keys, cfg.forEndpoint(ec))
+		}
+		for i, c := range keys {
+			name := "default"
+			if i > 0 {
+				name = cfg.Endpoints[i-1].Name
+			}
+			if err := validateLinearKey(ctx, c); err != nil {
+				errs = append(errs, fmt.Errorf("linear (%s endpoint): %w", name, err))
+			}
+		}
+	}
+
+	if cfg.FigmaAPIToken != "" {
+		var me figmaMe
+		if err := figmaRequest(ctx, cfg, "GET", "/v1/me", nil, &me); err != nil {
+			errs = append(errs, fmt.Errorf("figma: %w", err))
+		} else {
+			slog.Info("Figma token is valid", "user", me.Handle)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateLinearKey(ctx context.Context, cfg *Config) error {
+	if cfg.LinearAPIKey == "" {
+		slog.Warn("No Linear API key configured, skipping Linear check")
+		return nil
+	}
+
+	b, err := json.Marshal(GraphQLRequest{Query: linearViewerQuery})
+	if err != nil {
+		return err
+	}
+	var data linearViewerData
+	if err := executeLinearGraphQL(ctx, httpClient, cfg.linear(), b, &data); err != nil {
+		return err
+	}
+	slog.Info("Linear API key is valid", "viewer", data.Viewer.Name, "team_id", cfg.LinearTeamID)
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var errInvalidSignature = errors.New("invalid or missing webhook signature")
+
+// WebhookVerifier authenticates an inbound webhook delivery. Each provider
+// has its own signature scheme, so each gets its own implementation.
+type WebhookVerifier interface {
+	Verify(r *http.Request, body []byte) error
+}
+
+// FigmaVerifier checks the X-Figma-Signature HMAC against the configured
+// passcodes.
+type FigmaVerifier struct {
+	Passcodes []string
+}
+
+func (v FigmaVerifier) Verify(r *http.Request, body []byte) error {
+	keyIndex, ok := matchFigmaSignature(body, r.Header.Get(figmaSignatureHeader), v.Passcodes)
+	if !ok {
+		return errInvalidSignature
+	}
+	loggerFrom(r.Context()).Debug("Verified webhook signature", "passcode_index", keyIndex)
+	return nil
+}
+
+// verifyWebhook reads the request body up to maxBytes, rejects empty or
+// unverified deliveries and hands the body on to next for decoding.
+// Gzip-encoded bodies are decompressed first, and the limit applies to the
+// decompressed size so a small compressed payload can't expand unbounded.
+func verifyWebhook(v WebhookVerifier, maxBytes int64) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := loggerFrom(r.Context())
+
+			body, err := readBody(w, r, maxBytes)
+			r.Body.Close()
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					logger.Warn("Rejected oversized webhook body", "limit_bytes", maxErr.Limit)
+					writeJSONError(w, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge, fmt.Sprintf("Request body exceeds %d bytes", maxErr.Limit))
+					return
+				}
+				logger.Warn("Failed to read webhook body", "content_encoding", r.Header.Get("Content-Encoding"), "error", err)
+				writeJSONError(w, http.StatusBadRequest, errCodeReadFailed, "Failed to read request body")
+				return
+			}
+			r.Header.Del("Content-Encoding")
+
+			if len(bytes.TrimSpace(body)) == 0 {
+				logger.Warn("Rejected webhook with empty body", "content_length", r.ContentLength)
+				writeJSONError(w, http.StatusBadRequest, errCodeEmptyBody, "Request body is empty")
+				return
+			}
+
+			if err := v.Verify(r, body); err != nil {
+				logger.Warn("Rejected webhook that failed verification", "error", err)
+				writeJSONError(w, http.StatusUnauthorized, errCodeInvalidSignature, "Invalid or missing webhook signature")
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readBody reads r.Body, decompressing it when Content-Encoding is gzip.
+// Both the raw and decompressed streams are capped at maxBytes.
+func readBody(w http.ResponseWriter, r *http.Request, maxBytes int64) ([]byte, error) {
+	body := http.MaxBytesReader(w, r.Body, maxBytes)
+
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return io.ReadAll(body)
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		b, err := io.ReadAll(io.LimitReader(gz, maxBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(b)) > maxBytes {
+			return nil, &http.MaxBytesError{Limit: maxBytes}
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", r.Header.Get("Content-Encoding"))
+	}
+}
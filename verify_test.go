@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPasscode = "test-passcode"
+
+const fileUpdateWebhook = `{"event_type":"FILE_UPDATE","file_key":"abc123","file_name":"Design system","timestamp":"2026-01-02T15:04:05Z"}`
+
+// signFigma returns the X-Figma-Signature header Figma would send for body.
+func signFigma(body []byte, passcode string) string {
+	mac := hmac.New(sha256.New, []byte(passcode))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// errorCode returns the code of a JSON error response, or "" if the
+// response isn't one.
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body errorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return ""
+	}
+	return body.Error.Code
+}
+
+func TestVerifyWebhookEmptyBody(t *testing.T) {
+	rl := &relay{cfg: defaultConfig()}
+	handler := verifyWebhook(FigmaVerifier{Passcodes: []string{testPasscode}}, 1<<20)(rl.createIssueHandler(&endpoint{cfg: rl.cfg}))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode string
+	}{
+		{"zero-length", "", errCodeEmptyBody},
+		{"whitespace only", " \r\n\t", errCodeEmptyBody},
+		{"truncated JSON", "{", errCodeInvalidJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/create-issue", strings.NewReader(tt.body))
+			req.Header.Set(figmaSignatureHeader, signFigma([]byte(tt.body), testPasscode))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if got := errorCode(t, rec); got != tt.wantCode {
+				t.Errorf("error code = %q, want %q (body %s)", got, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
+
+func gzipped(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyWebhookGzip(t *testing.T) {
+	const maxBytes = 1 << 10
+	payload := []byte(fileUpdateWebhook)
+	// Whitespace padding compresses to far under maxBytes on the wire.
+	bomb := append(bytes.Repeat([]byte(" "), 4*maxBytes), payload...)
+
+	tests := []struct {
+		name       string
+		wire       []byte
+		signed     []byte
+		wantStatus int
+		wantCode   string
+	}{
+		// Figma signs the JSON it sends, so the signature is checked against
+		// the decompressed body.
+		{"valid gzip", gzipped(t, payload), payload, http.StatusOK, ""},
+		{"decompresses past the limit", gzipped(t, bomb), bomb, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge},
+		{"not actually gzip", payload, payload, http.StatusBadRequest, errCodeReadFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.wire) > maxBytes {
+				t.Fatalf("compressed body is %d bytes, over the %d byte limit", len(tt.wire), maxBytes)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(tt.wire))
+			req.Header.Set("Content-Encoding", "gzip")
+			req.Header.Set(figmaSignatureHeader, signFigma(tt.signed, testPasscode))
+			rec := httptest.NewRecorder()
+			verifyWebhook(FigmaVerifier{Passcodes: []string{testPasscode}}, maxBytes)(echoBody).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode != "" {
+				if got := errorCode(t, rec); got != tt.wantCode {
+					t.Errorf("error code = %q, want %q", got, tt.wantCode)
+				}
+				return
+			}
+			if !bytes.Equal(rec.Body.Bytes(), payload) {
+				t.Errorf("handler got body %q, want the decompressed payload", rec.Body)
+			}
+		})
+	}
+}
+
+// echoBody answers with the request body the middleware passed on.
+var echoBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.Copy(w, r.Body)
+})
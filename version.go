@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+func currentVersion() versionInfo {
+	info := versionInfo{Version: version, Commit: commit, BuildDate: buildDate}
+
+	// Fall back to the VCS stamp Go embeds when ldflags weren't provided.
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "unknown":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "unknown":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+
+	return info
+}
+
+func (v versionInfo) String() string {
+	return fmt.Sprintf("relay %s (commit %s, built %s)", v.Version, v.Commit, v.BuildDate)
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentVersion())
+}
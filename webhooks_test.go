@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// handlerTransport serves outbound requests from an in-process handler,
+// so every call the relay makes lands on the mock whatever its URL.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+// TestWebhookGolden replays every payload in testdata/webhooks through the
+// signed webhook route and compares the requests Linear receives with
+// testdata/golden. Run go test -run TestWebhookGolden -update after an
+// intended change to the issue format.
+func TestWebhookGolden(t *testing.T) {
+	payloads, err := filepath.Glob(filepath.Join("testdata", "webhooks", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) == 0 {
+		t.Fatal("no payloads in testdata/webhooks")
+	}
+
+	for _, path := range payloads {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			body, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := replayWebhook(t, body)
+
+			golden := filepath.Join("testdata", "golden", name+".json")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Linear requests differ from %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// replayWebhook posts a signed payload to a fresh relay backed by a mock
+// Linear, waits for the queue to drain, and returns the GraphQL requests
+// Linear received as indented JSON.
+func replayWebhook(t *testing.T, payload []byte) []byte {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		requests = []any{}
+	)
+	linear := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var req any
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Errorf("Linear request isn't JSON: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		io.WriteString(w, `{"data":{"issueCreate":{"issue":{"id":"issue-1","title":"t","url":"https://linear.app/test/issue/ENG-1"}}}}`)
+	})
+
+	saved := httpClient
+	httpClient = &http.Client{Transport: handlerTransport{linear}}
+	defer func() { httpClient = saved }()
+
+	cfg := defaultConfig()
+	cfg.LinearAPIKey = "lin_api_test"
+	cfg.LinearTeamID = testTeamID
+	cfg.FigmaWebhookPasscode = testPasscode
+	// The fixtures have fixed timestamps.
+	cfg.MaxEventAge = 0
+	// Opt-in events are enabled so every fixture files an issue.
+	enabled := true
+	cfg.EventSettings = make(map[string]EventSettings)
+	for eventType := range optInEvents {
+		cfg.EventSettings[eventType] = EventSettings{Enabled: &enabled}
+	}
+	rl, err := newRelay(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, defaultEndpointPath, bytes.NewReader(payload))
+	req.Header.Set(figmaSignatureHeader, signFigma(payload, testPasscode))
+	rec := httptest.NewRecorder()
+	rl.routes().ServeHTTP(rec, req)
+	if rec.Code >= 300 {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	rl.Close(context.Background())
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(requests); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}